@@ -0,0 +1,412 @@
+package websitepoller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redactedValue           string        = "REDACTED"
+	defaultAdminPollTimeout time.Duration = time.Minute
+)
+
+// AdminOptions contains options about the admin API
+type AdminOptions struct {
+	// Setup is called with each poller created through the API, before it
+	// is added to the manager, i.e. to set its handler func. If it returns
+	// an error, the poller is not added.
+	Setup func(Poller) error
+	// PollTimeout is the maximum duration of the polls requested through
+	// the API, handler func included. Default is 1 minute
+	PollTimeout time.Duration
+	// AllowPage is called with each page submitted through the API, before
+	// its poller is created: if it returns an error, the page is rejected.
+	// By default, pages that read or write local files or read environment
+	// variables - through ${env:...} and ${file:...} variables, secrets,
+	// TLS files or snapshots - are rejected, as anyone who can reach the
+	// API could read them.
+	AllowPage func(*Page) error
+}
+
+type adminAPI struct {
+	manager *Manager
+	opts    AdminOptions
+	// polling contains the IDs of the pollers with a poll requested through
+	// the API still running
+	polling map[string]bool
+	lock    sync.Mutex
+}
+
+// adminPoller is how a poller is shown by the admin API
+type adminPoller struct {
+	Status
+	Config *Page `json:"config,omitempty"`
+}
+
+// NewAdminHandler returns an http.Handler that exposes a JSON API to inspect
+// and control the pollers of the manager:
+//
+//	GET    /pollers             list the pollers and their status
+//	POST   /pollers             add a poller for the page in the body
+//	GET    /pollers/{id}        show the status and config of a poller
+//	DELETE /pollers/{id}        stop and remove a poller
+//	POST   /pollers/{id}/poll   poll right away, unless already polling
+//	POST   /pollers/{id}/pause  pause scheduled polls
+//	POST   /pollers/{id}/resume resume scheduled polls
+//
+// Pages are in the same format as YAML files, in JSON. Values that may be
+// secret, i.e. sensitive headers and bodies, are redacted from configs, but
+// the API gives full control over the pollers: only mount it behind
+// authentication. Use http.StripPrefix to mount it under a path.
+func NewAdminHandler(m *Manager, opts AdminOptions) http.Handler {
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = defaultAdminPollTimeout
+	}
+	if opts.AllowPage == nil {
+		opts.AllowPage = checkLocalAccess
+	}
+	api := &adminAPI{manager: m, opts: opts, polling: map[string]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pollers", api.list)
+	mux.HandleFunc("POST /pollers", api.add)
+	mux.HandleFunc("GET /pollers/{id}", api.get)
+	mux.HandleFunc("DELETE /pollers/{id}", api.remove)
+	mux.HandleFunc("POST /pollers/{id}/poll", api.poll)
+	mux.HandleFunc("POST /pollers/{id}/pause", api.pause)
+	mux.HandleFunc("POST /pollers/{id}/resume", api.resume)
+
+	return mux
+}
+
+func (a *adminAPI) list(w http.ResponseWriter, r *http.Request) {
+	pollers := []adminPoller{}
+	for _, p := range a.manager.Pollers() {
+		pollers = append(pollers, adminPoller{Status: p.Status()})
+	}
+
+	writeJSON(w, http.StatusOK, pollers)
+}
+
+func (a *adminAPI) add(w http.ResponseWriter, r *http.Request) {
+	var page Page
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&page); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.opts.AllowPage(&page); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	p, err := New(&page)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if a.opts.Setup != nil {
+		if err := a.opts.Setup(p); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := a.manager.Add(p); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDuplicatePoller) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, showPoller(p))
+}
+
+func (a *adminAPI) get(w http.ResponseWriter, r *http.Request) {
+	if p := a.poller(w, r); p != nil {
+		writeJSON(w, http.StatusOK, showPoller(p))
+	}
+}
+
+func (a *adminAPI) remove(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.Remove(r.PathValue("id")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminAPI) poll(w http.ResponseWriter, r *http.Request) {
+	p := a.poller(w, r)
+	if p == nil {
+		return
+	}
+
+	// -- Requests sent while a poll is running do not start another one,
+	// so that they cannot pile up
+	id := r.PathValue("id")
+	a.lock.Lock()
+	if a.polling[id] {
+		a.lock.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("%w: %s", ErrPollInProgress, id))
+		return
+	}
+	a.polling[id] = true
+	a.lock.Unlock()
+
+	// -- The request's context ends with the response
+	ctx, canc := context.WithTimeout(context.Background(), a.opts.PollTimeout)
+	go func() {
+		defer func() {
+			canc()
+			a.lock.Lock()
+			delete(a.polling, id)
+			a.lock.Unlock()
+		}()
+		p.Poll(ctx)
+	}()
+	writeJSON(w, http.StatusAccepted, adminPoller{Status: p.Status()})
+}
+
+func (a *adminAPI) pause(w http.ResponseWriter, r *http.Request) {
+	if p := a.poller(w, r); p != nil {
+		p.Pause()
+		writeJSON(w, http.StatusOK, adminPoller{Status: p.Status()})
+	}
+}
+
+func (a *adminAPI) resume(w http.ResponseWriter, r *http.Request) {
+	if p := a.poller(w, r); p != nil {
+		p.Resume()
+		writeJSON(w, http.StatusOK, adminPoller{Status: p.Status()})
+	}
+}
+
+// poller returns the poller with the ID in the path, or writes an error and
+// returns nil if it does not exist
+func (a *adminAPI) poller(w http.ResponseWriter, r *http.Request) Poller {
+	id := r.PathValue("id")
+	p := a.manager.Get(id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrPollerNotFound, id))
+	}
+
+	return p
+}
+
+// checkLocalAccess returns a *ValidationError with the fields of the page
+// that access local files or environment variables, or nil if there are
+// none
+func checkLocalAccess(page *Page) error {
+	v := &validator{}
+	local := func(path, what string) {
+		v.check(path, fmt.Errorf("%w: %s", ErrPageNotAllowed, what))
+	}
+	templates := func(path string, s string) {
+		names, _ := templateVars(s)
+		for _, name := range names {
+			switch fn, _, _ := strings.Cut(name, ":"); fn {
+			case "env":
+				local(path, "${"+name+"} reads environment variables")
+			case "file":
+				local(path, "${"+name+"} reads local files")
+			}
+		}
+	}
+	request := func(path, url string, headers Headers, body string) {
+		templates(joinPath(path, "url"), url)
+		for _, h := range headers {
+			for _, val := range h.Values {
+				templates(joinPath(path, "headers"), val)
+			}
+		}
+		templates(joinPath(path, "body"), body)
+	}
+	secret := func(path string, s *Secret) {
+		if s != nil && (len(s.Env) > 0 || len(s.File) > 0) {
+			local(path, "secrets are read from local files or environment variables")
+		}
+	}
+
+	request("", page.URL, page.Headers, page.Body)
+	for i, s := range page.Steps {
+		request(joinPath("steps", indexPath(i)), s.URL, s.Headers, s.Body)
+	}
+
+	if auth := page.AuthOptions; auth != nil {
+		if auth.Basic != nil {
+			secret("auth.basic.password", &auth.Basic.Password)
+		}
+		if auth.Bearer != nil {
+			secret("auth.bearer.token", &auth.Bearer.Token)
+		}
+		if auth.OAuth2 != nil {
+			secret("auth.oauth2.clientSecret", &auth.OAuth2.ClientSecret)
+		}
+		if auth.AWSSigV4 != nil {
+			secret("auth.awsSigV4.accessKeyID", &auth.AWSSigV4.AccessKeyID)
+			secret("auth.awsSigV4.secretAccessKey", &auth.AWSSigV4.SecretAccessKey)
+			secret("auth.awsSigV4.sessionToken", auth.AWSSigV4.SessionToken)
+		}
+		if auth.HMAC != nil {
+			secret("auth.hmac.key", &auth.HMAC.Key)
+		}
+	}
+
+	if opts := page.TLSOptions; opts != nil {
+		for _, f := range []struct{ path, file string }{
+			{"tls.caFile", opts.CAFile},
+			{"tls.certFile", opts.CertFile},
+			{"tls.keyFile", opts.KeyFile},
+		} {
+			if len(f.file) > 0 {
+				local(f.path, "reads local files")
+			}
+		}
+	}
+
+	if page.SnapshotOptions != nil {
+		local("snapshots", "writes local files")
+	}
+
+	return v.err()
+}
+
+func showPoller(p Poller) adminPoller {
+	page := redactPage(p.Page())
+	return adminPoller{Status: p.Status(), Config: &page}
+}
+
+// redactPage returns a copy of the page with the values that may be secret
+// redacted: sensitive headers, bodies, i.e. of login forms, the values of
+// query strings and of the parameters sent to OAuth2 token endpoints
+func redactPage(page Page) Page {
+	page.URL = redactURL(page.URL)
+	page.Headers = redactHeaders(page.Headers)
+	page.Body = redactString(page.Body)
+
+	steps := make([]Step, len(page.Steps))
+	for i, s := range page.Steps {
+		s.URL = redactURL(s.URL)
+		s.Headers = redactHeaders(s.Headers)
+		s.Body = redactString(s.Body)
+		steps[i] = s
+	}
+	if page.Steps != nil {
+		page.Steps = steps
+	}
+
+	if page.AuthOptions != nil && page.AuthOptions.OAuth2 != nil {
+		auth := *page.AuthOptions
+		oauth2 := *auth.OAuth2
+		oauth2.TokenURL = redactURL(oauth2.TokenURL)
+		if oauth2.Params != nil {
+			oauth2.Params = map[string]string{}
+			for key := range auth.OAuth2.Params {
+				oauth2.Params[key] = redactedValue
+			}
+		}
+		auth.OAuth2 = &oauth2
+		page.AuthOptions = &auth
+	}
+
+	return page
+}
+
+func redactString(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+
+	return redactedValue
+}
+
+// redactURL redacts the password and the values of the query string of
+// the URL, which may contain variables and thus is not parsed as a whole
+func redactURL(raw string) string {
+	rest, fragment, hasFragment := strings.Cut(raw, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+
+	if u, err := url.Parse(base); err == nil && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+			base = u.String()
+		}
+	}
+
+	if hasQuery {
+		pairs := strings.Split(query, "&")
+		for i, pair := range pairs {
+			if key, _, hasValue := strings.Cut(pair, "="); hasValue {
+				pairs[i] = key + "=" + redactedValue
+			}
+		}
+		base += "?" + strings.Join(pairs, "&")
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+
+	return base
+}
+
+func redactHeaders(headers Headers) Headers {
+	if headers == nil {
+		return nil
+	}
+
+	redacted := make(Headers, len(headers))
+	for i, h := range headers {
+		redacted[i] = h
+		if !isSensitiveHeader(h.Key) || h.Values == nil {
+			continue
+		}
+
+		redacted[i].Values = make([]string, len(h.Values))
+		for j := range h.Values {
+			redacted[i].Values[j] = redactedValue
+		}
+	}
+
+	return redacted
+}
+
+func isSensitiveHeader(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+
+	for _, word := range []string{"token", "secret", "key", "signature", "password"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("could not write admin response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,265 @@
+package websitepoller
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHMACHeader       string = "X-Signature"
+	defaultHMACStringToSign string = "${method}\n${url}\n${timestamp}\n${body}"
+)
+
+// Authenticator authenticates a request right before it is sent, i.e. by
+// setting the Authorization header or by signing it.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc is a function that implements Authenticator
+type AuthenticatorFunc func(*http.Request) error
+
+// Authenticate calls f(req)
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// Load returns the value of the secret
+func (s Secret) Load() (string, error) {
+	switch {
+	case len(s.Env) > 0 && len(s.File) == 0:
+		val, exists := os.LookupEnv(s.Env)
+		if !exists {
+			return "", fmt.Errorf("%w: environment variable %s is not set", ErrInvalidSecret, s.Env)
+		}
+		return val, nil
+	case len(s.File) > 0 && len(s.Env) == 0:
+		content, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("%w: exactly one of env or file must be provided", ErrInvalidSecret)
+	}
+}
+
+func (s Secret) validate() error {
+	if (len(s.Env) > 0) == (len(s.File) > 0) {
+		return fmt.Errorf("%w: exactly one of env or file must be provided", ErrInvalidSecret)
+	}
+
+	return nil
+}
+
+// parseAuthOptions returns the authenticator of the page. Providers that
+// perform requests of their own, i.e. OAuth2, perform them through
+// transport, so that they use the same TLS and transport options as the
+// page. A nil transport means the default one.
+func parseAuthOptions(opts *AuthOptions, transport http.RoundTripper) (Authenticator, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	var (
+		auth      Authenticator
+		providers int
+		err       error
+	)
+
+	// -- Problems are returned under the name of the provider, i.e.
+	// oauth2.tokenURL
+	v := &validator{}
+	if opts.Basic != nil {
+		providers++
+		auth, err = newBasicAuth(opts.Basic)
+		v.check("basic", err)
+	}
+	if opts.Bearer != nil {
+		providers++
+		auth, err = newBearerAuth(opts.Bearer)
+		v.check("bearer", err)
+	}
+	if opts.OAuth2 != nil {
+		providers++
+		auth, err = newOAuth2Auth(opts.OAuth2, transport)
+		v.check("oauth2", err)
+	}
+	if opts.AWSSigV4 != nil {
+		providers++
+		auth, err = newAWSSigV4Auth(opts.AWSSigV4)
+		v.check("awsSigV4", err)
+	}
+	if opts.HMAC != nil {
+		providers++
+		auth, err = newHMACAuth(opts.HMAC)
+		v.check("hmac", err)
+	}
+
+	if providers != 1 {
+		return nil, fmt.Errorf("%w: exactly one provider must be defined", ErrInvalidAuth)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+func newBasicAuth(opts *BasicAuth) (Authenticator, error) {
+	v := &validator{}
+	if !v.check("password", opts.Password.validate()) {
+		return nil, v.err()
+	}
+
+	return AuthenticatorFunc(func(req *http.Request) error {
+		password, err := opts.Password.Load()
+		if err != nil {
+			return err
+		}
+
+		req.SetBasicAuth(opts.Username, password)
+		return nil
+	}), nil
+}
+
+func newBearerAuth(opts *BearerAuth) (Authenticator, error) {
+	v := &validator{}
+	if !v.check("token", opts.Token.validate()) {
+		return nil, v.err()
+	}
+
+	return AuthenticatorFunc(func(req *http.Request) error {
+		token, err := opts.Token.Load()
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}), nil
+}
+
+type hmacAuth struct {
+	opts     HMACAuth
+	hashFunc func() hash.Hash
+}
+
+func newHMACAuth(opts *HMACAuth) (Authenticator, error) {
+	v := &validator{}
+	v.check("key", opts.Key.validate())
+
+	a := &hmacAuth{opts: *opts}
+	switch strings.ToLower(opts.Algorithm) {
+	case "", "sha256":
+		a.hashFunc = sha256.New
+	case "sha1":
+		a.hashFunc = sha1.New
+	case "sha512":
+		a.hashFunc = sha512.New
+	default:
+		v.check("algorithm", fmt.Errorf("%w: unsupported hmac algorithm %s", ErrInvalidAuth, opts.Algorithm))
+	}
+
+	switch strings.ToLower(opts.Encoding) {
+	case "", "hex", "base64":
+	default:
+		v.check("encoding", fmt.Errorf("%w: unsupported hmac encoding %s", ErrInvalidAuth, opts.Encoding))
+	}
+
+	if len(a.opts.Header) == 0 {
+		a.opts.Header = defaultHMACHeader
+	}
+	if len(a.opts.StringToSign) == 0 {
+		a.opts.StringToSign = defaultHMACStringToSign
+	}
+
+	// -- Fail now rather than on each poll
+	names, err := templateVars(a.opts.StringToSign)
+	v.check("stringToSign", err)
+	for _, name := range names {
+		switch name {
+		case "method", "url", "path", "query", "timestamp", "body":
+		default:
+			v.check("stringToSign", fmt.Errorf("%w: hmac string to sign: %s", ErrUnknownVariable, name))
+		}
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Authenticate signs the request and writes the signature in the header
+func (a *hmacAuth) Authenticate(req *http.Request) error {
+	key, err := a.opts.Key.Load()
+	if err != nil {
+		return err
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	vars := map[string]string{
+		"method":    req.Method,
+		"url":       req.URL.String(),
+		"path":      req.URL.EscapedPath(),
+		"query":     req.URL.RawQuery,
+		"timestamp": timestamp,
+		"body":      string(body),
+	}
+	toSign, err := expandTemplate(a.opts.StringToSign, func(name string) (string, error) {
+		return vars[name], nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(a.hashFunc, []byte(key))
+	mac.Write([]byte(toSign))
+	sum := mac.Sum(nil)
+
+	signature := hex.EncodeToString(sum)
+	if strings.ToLower(a.opts.Encoding) == "base64" {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	req.Header.Set(a.opts.Header, a.opts.Prefix+signature)
+	if len(a.opts.TimestampHeader) > 0 {
+		req.Header.Set(a.opts.TimestampHeader, timestamp)
+	}
+
+	return nil
+}
+
+// requestBody returns a copy of the body of the request, without consuming
+// it.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return nil, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
@@ -0,0 +1,125 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// oauth2RefreshBefore is how long before its expiration a token is
+	// refreshed
+	oauth2RefreshBefore = 30 * time.Second
+	// oauth2DefaultExpiry is used when the token endpoint does not say
+	// when the token expires
+	oauth2DefaultExpiry = 5 * time.Minute
+)
+
+type oauth2Auth struct {
+	opts    OAuth2Auth
+	client  *http.Client
+	token   string
+	expires time.Time
+	lock    sync.Mutex
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func newOAuth2Auth(opts *OAuth2Auth, transport http.RoundTripper) (Authenticator, error) {
+	v := &validator{}
+	_, err := parseURL(opts.TokenURL)
+	v.check("tokenURL", err)
+	if len(opts.ClientID) == 0 {
+		v.check("clientID", fmt.Errorf("%w: oauth2 client id is required", ErrInvalidAuth))
+	}
+	v.check("clientSecret", opts.ClientSecret.validate())
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return &oauth2Auth{
+		opts: *opts,
+		client: &http.Client{
+			Timeout:   time.Duration(defaultHTTPClientTimeout) * time.Second,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Authenticate sets the Authorization header with the current token,
+// requesting a new one if it is about to expire
+func (a *oauth2Auth) Authenticate(req *http.Request) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if len(a.token) == 0 || time.Now().Add(oauth2RefreshBefore).After(a.expires) {
+		if err := a.refresh(req); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *oauth2Auth) refresh(req *http.Request) error {
+	secret, err := a.opts.ClientSecret.Load()
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.opts.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.opts.Scopes, " "))
+	}
+	for key, val := range a.opts.Params {
+		form.Set(key, val)
+	}
+
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, a.opts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.SetBasicAuth(url.QueryEscape(a.opts.ClientID), url.QueryEscape(secret))
+
+	resp, err := a.client.Do(tokenReq)
+	if err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth2: token endpoint returned %s", resp.Status)
+	}
+
+	var token oauth2Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	if len(token.AccessToken) == 0 {
+		return fmt.Errorf("oauth2: token endpoint returned no access token")
+	}
+
+	expiresIn := oauth2DefaultExpiry
+	if token.ExpiresIn > 0 {
+		expiresIn = time.Duration(token.ExpiresIn) * time.Second
+	}
+	a.token, a.expires = token.AccessToken, time.Now().Add(expiresIn)
+
+	return nil
+}
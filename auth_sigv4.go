@@ -0,0 +1,182 @@
+package websitepoller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  string = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat string = "20060102T150405Z"
+)
+
+type awsSigV4Auth struct {
+	opts AWSSigV4Auth
+	now  func() time.Time
+}
+
+func newAWSSigV4Auth(opts *AWSSigV4Auth) (Authenticator, error) {
+	v := &validator{}
+	if len(opts.Region) == 0 {
+		v.check("region", fmt.Errorf("%w: aws region is required", ErrInvalidAuth))
+	}
+	if len(opts.Service) == 0 {
+		v.check("service", fmt.Errorf("%w: aws service is required", ErrInvalidAuth))
+	}
+
+	v.check("accessKeyID", opts.AccessKeyID.validate())
+	v.check("secretAccessKey", opts.SecretAccessKey.validate())
+	if opts.SessionToken != nil {
+		v.check("sessionToken", opts.SessionToken.validate())
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return &awsSigV4Auth{opts: *opts, now: time.Now}, nil
+}
+
+// Authenticate signs the request with AWS Signature Version 4
+func (a *awsSigV4Auth) Authenticate(req *http.Request) error {
+	accessKey, err := a.opts.AccessKeyID.Load()
+	if err != nil {
+		return err
+	}
+	secretKey, err := a.opts.SecretAccessKey.Load()
+	if err != nil {
+		return err
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	now := a.now().UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.opts.SessionToken != nil {
+		token, err := a.opts.SessionToken.Load()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	if a.opts.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// -- Canonical request
+	signedHeaders, canonicalHeaders := sigV4Headers(req)
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// -- String to sign and signature
+	scope := strings.Join([]string{date, a.opts.Region, a.opts.Service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, a.opts.Region)
+	key = hmacSHA256(key, a.opts.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// sigV4Headers returns the signed headers and the canonical headers. Only
+// host, content-type and the x-amz-* headers are signed, as other headers
+// may be changed by proxies.
+func sigV4Headers(req *http.Request) (string, string) {
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": strings.TrimSpace(host)}
+
+	for key, vals := range req.Header {
+		lower := strings.ToLower(key)
+		if lower != "content-type" && !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[lower] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var canonical strings.Builder
+	for _, key := range keys {
+		canonical.WriteString(key + ":" + headers[key] + "\n")
+	}
+
+	return strings.Join(keys, ";"), canonical.String()
+}
+
+func sigV4Query(query url.Values) string {
+	pairs := [][2]string{}
+	for key, vals := range query {
+		for _, val := range vals {
+			pairs = append(pairs, [2]string{sigV4Escape(key), sigV4Escape(val)})
+		}
+	}
+
+	// -- Sorted by key and then by value, after encoding them
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,268 @@
+package websitepoller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	a := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "secret")
+	a.NoError(ioutil.WriteFile(file, []byte("from-file\n"), 0600))
+	t.Setenv("POLLER_TEST_SECRET", "from-env")
+
+	val, err := Secret{File: file}.Load()
+	a.NoError(err)
+	a.Equal("from-file", val)
+
+	val, err = Secret{Env: "POLLER_TEST_SECRET"}.Load()
+	a.NoError(err)
+	a.Equal("from-env", val)
+
+	_, err = Secret{Env: "POLLER_TEST_MISSING"}.Load()
+	a.True(errors.Is(err, ErrInvalidSecret))
+	_, err = Secret{}.Load()
+	a.True(errors.Is(err, ErrInvalidSecret))
+	a.Error(Secret{Env: "A", File: file}.validate())
+}
+
+func TestParseAuthOptions(t *testing.T) {
+	a := assert.New(t)
+	secret := Secret{Env: "POLLER_TEST_SECRET"}
+
+	cases := []struct {
+		arg    *AuthOptions
+		expErr error
+	}{
+		{},
+		{
+			arg: &AuthOptions{Bearer: &BearerAuth{Token: secret}},
+		},
+		{
+			arg:    &AuthOptions{},
+			expErr: ErrInvalidAuth,
+		},
+		{
+			arg:    &AuthOptions{Bearer: &BearerAuth{Token: secret}, Basic: &BasicAuth{Password: secret}},
+			expErr: ErrInvalidAuth,
+		},
+		{
+			arg:    &AuthOptions{Basic: &BasicAuth{Username: "user"}},
+			expErr: ErrInvalidSecret,
+		},
+		{
+			arg:    &AuthOptions{OAuth2: &OAuth2Auth{TokenURL: "https://example.com/token", ClientSecret: secret}},
+			expErr: ErrInvalidAuth,
+		},
+		{
+			arg:    &AuthOptions{AWSSigV4: &AWSSigV4Auth{AccessKeyID: secret, SecretAccessKey: secret}},
+			expErr: ErrInvalidAuth,
+		},
+		{
+			arg:    &AuthOptions{HMAC: &HMACAuth{Key: secret, Algorithm: "md5"}},
+			expErr: ErrInvalidAuth,
+		},
+		{
+			arg:    &AuthOptions{HMAC: &HMACAuth{Key: secret, StringToSign: "${method} ${unknown}"}},
+			expErr: ErrUnknownVariable,
+		},
+	}
+
+	for i, currCase := range cases {
+		_, err := parseAuthOptions(currCase.arg, nil)
+
+		if currCase.expErr != nil {
+			a.True(errors.Is(err, currCase.expErr), fmt.Sprintf("case %d failed: %v", i, err))
+		} else {
+			a.NoError(err, fmt.Sprintf("case %d failed", i))
+		}
+	}
+}
+
+func TestStaticAuth(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_SECRET", "s3cr3t")
+
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	p, err := New(&Page{
+		URL:         server.URL,
+		AuthOptions: &AuthOptions{Basic: &BasicAuth{Username: "user", Password: Secret{Env: "POLLER_TEST_SECRET"}}},
+	})
+	a.NoError(err)
+	p.(*pagePoller).poll(context.Background())
+
+	user, password, ok := received.BasicAuth()
+	a.True(ok)
+	a.Equal("user", user)
+	a.Equal("s3cr3t", password)
+
+	p, err = New(&Page{
+		URL:         server.URL,
+		AuthOptions: &AuthOptions{Bearer: &BearerAuth{Token: Secret{Env: "POLLER_TEST_SECRET"}}},
+	})
+	a.NoError(err)
+	p.(*pagePoller).poll(context.Background())
+	a.Equal("Bearer s3cr3t", received.Header.Get("Authorization"))
+}
+
+func TestOAuth2Auth(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_SECRET", "client-secret")
+
+	tokens, expiresIn := 0, 3600
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "client" || secret != "client-secret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		tokens++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`, tokens, expiresIn)
+	})
+	var authorization string
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := New(&Page{
+		URL: server.URL + "/page",
+		AuthOptions: &AuthOptions{OAuth2: &OAuth2Auth{
+			TokenURL:     server.URL + "/token",
+			ClientID:     "client",
+			ClientSecret: Secret{Env: "POLLER_TEST_SECRET"},
+			Scopes:       []string{"read", "write"},
+		}},
+	})
+	a.NoError(err)
+
+	// -- The token is reused until it is about to expire
+	p.(*pagePoller).poll(context.Background())
+	a.Equal("Bearer token-1", authorization)
+	expiresIn = 10
+	p.(*pagePoller).poll(context.Background())
+	a.Equal("Bearer token-1", authorization)
+
+	p.(*pagePoller).auth.(*oauth2Auth).expires = time.Now().Add(time.Second)
+	p.(*pagePoller).poll(context.Background())
+	a.Equal("Bearer token-2", authorization)
+	p.(*pagePoller).poll(context.Background())
+	a.Equal("Bearer token-3", authorization)
+	a.Equal(3, tokens)
+}
+
+func TestOAuth2AuthTLSOptions(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_SECRET", "client-secret")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+	})
+	var authorization string
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	// -- The token endpoint is only trusted through the CA of the page
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	a.NoError(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	p, err := New(&Page{
+		URL: server.URL + "/page",
+		AuthOptions: &AuthOptions{OAuth2: &OAuth2Auth{
+			TokenURL:     server.URL + "/token",
+			ClientID:     "client",
+			ClientSecret: Secret{Env: "POLLER_TEST_SECRET"},
+		}},
+		TLSOptions: &TLSOptions{CAFile: caFile},
+	})
+	a.NoError(err)
+
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+	a.NoError(res.Err)
+	a.Equal("Bearer token", authorization)
+}
+
+func TestAWSSigV4Auth(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_AKID", "AKIDEXAMPLE")
+	t.Setenv("POLLER_TEST_SECRET", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	// -- get-vanilla from the AWS Signature Version 4 test suite
+	auth, err := newAWSSigV4Auth(&AWSSigV4Auth{
+		AccessKeyID:     Secret{Env: "POLLER_TEST_AKID"},
+		SecretAccessKey: Secret{Env: "POLLER_TEST_SECRET"},
+		Region:          "us-east-1",
+		Service:         "service",
+	})
+	a.NoError(err)
+	auth.(*awsSigV4Auth).now = func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	a.NoError(err)
+	a.NoError(auth.Authenticate(req))
+
+	a.Equal("20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	a.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, "+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+
+	a.Equal("a=1&a=2&b=x%20y&b-c=3", sigV4Query(map[string][]string{"b": {"x y"}, "a": {"2", "1"}, "b-c": {"3"}}))
+}
+
+func TestHMACAuth(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_SECRET", "key")
+
+	auth, err := newHMACAuth(&HMACAuth{
+		Key:             Secret{Env: "POLLER_TEST_SECRET"},
+		Prefix:          "sha256=",
+		TimestampHeader: "X-Timestamp",
+	})
+	a.NoError(err)
+
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/hook?a=1", strings.NewReader(`{"a":1}`))
+	a.NoError(err)
+	a.NoError(auth.Authenticate(req))
+
+	timestamp := req.Header.Get("X-Timestamp")
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("POST\nhttps://example.com/hook?a=1\n" + timestamp + "\n" + `{"a":1}`))
+	a.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get(defaultHMACHeader))
+
+	// -- The body must still be readable
+	body, err := ioutil.ReadAll(req.Body)
+	a.NoError(err)
+	a.Equal(`{"a":1}`, string(body))
+}
@@ -0,0 +1,34 @@
+package websitepoller
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+const (
+	defaultMaxBodySize int64 = 10 << 20
+	// maxDrainSize is how much of a truncated body is discarded to let the
+	// connection be reused. Larger bodies are not worth it: the connection
+	// is closed instead.
+	maxDrainSize int64 = 256 << 10
+)
+
+// readBody reads at most max bytes from body, then drains and closes it.
+// truncated is true if the body was larger than max.
+func readBody(body io.ReadCloser, max int64) (data []byte, truncated bool, err error) {
+	defer body.Close()
+
+	data, err = ioutil.ReadAll(io.LimitReader(body, max+1))
+	if int64(len(data)) > max {
+		data, truncated = data[:max], true
+	}
+	if err != nil {
+		return
+	}
+
+	if truncated {
+		io.CopyN(ioutil.Discard, body, maxDrainSize)
+	}
+
+	return
+}
@@ -0,0 +1,57 @@
+package websitepoller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+func parseChangeDetectionOptions(opts *ChangeDetectionOptions, exts []*extractor) (enabled bool, fields []string, err error) {
+	if opts == nil {
+		return
+	}
+
+	enabled = true
+	if len(opts.Fields) == 0 {
+		for _, e := range exts {
+			fields = append(fields, e.name)
+		}
+		return
+	}
+
+	defined := map[string]bool{}
+	for _, e := range exts {
+		defined[e.name] = true
+	}
+
+	for _, f := range opts.Fields {
+		if !defined[f] {
+			return false, nil, fmt.Errorf("%w: %s", ErrUnknownChangeField, f)
+		}
+	}
+
+	fields = opts.Fields
+	return
+}
+
+// contentHash returns the hash of the given fields or, if no fields are
+// provided, of the whole body.
+func contentHash(body []byte, values map[string]string, fields []string) string {
+	h := sha256.New()
+
+	if len(fields) == 0 {
+		h.Write(body)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	for _, f := range fields {
+		// A missing field is different from an empty one
+		if val, exists := values[f]; exists {
+			fmt.Fprintf(h, "%s=%q\n", f, val)
+		} else {
+			fmt.Fprintf(h, "%s!\n", f)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
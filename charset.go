@@ -0,0 +1,160 @@
+package websitepoller
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// maxMetaPrescan is how many bytes of an HTML document are searched for
+	// a meta tag declaring the charset, as in the HTML specification
+	maxMetaPrescan  int    = 1024
+	fallbackCharset string = "windows-1252"
+)
+
+var (
+	utf8BOM = []byte{0xef, 0xbb, 0xbf}
+	boms    = []struct {
+		bom     []byte
+		charset string
+	}{
+		{utf8BOM, "utf-8"},
+		{[]byte{0xfe, 0xff}, "utf-16be"},
+		{[]byte{0xff, 0xfe}, "utf-16le"},
+	}
+)
+
+// toUTF8 transcodes the body of the response to UTF-8 and returns it along
+// with the name of the original charset. The charset is taken from the
+// byte order mark, the Content-Type header or, for HTML documents, a meta
+// tag. If none of them declares it, the body is assumed to be UTF-8 if it
+// is valid UTF-8, or windows-1252 otherwise. Bodies that are not text are
+// returned as they are, with no charset.
+func toUTF8(resp *http.Response, body []byte) ([]byte, string, error) {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil && len(contentType) > 0 {
+		return body, "", nil
+	}
+	if !isText(mediaType) {
+		return body, "", nil
+	}
+
+	name := detectCharset(body, mediaType, params["charset"])
+	enc, name := charset.Lookup(name)
+	if enc == nil {
+		return body, "", nil
+	}
+
+	if name == "utf-8" {
+		return bytes.TrimPrefix(body, utf8BOM), name, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, name, err
+	}
+	// -- Decoders keep the byte order mark, if any
+	decoded = bytes.TrimPrefix(decoded, utf8BOM)
+
+	if _, exists := params["charset"]; exists {
+		params["charset"] = "utf-8"
+		resp.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	}
+
+	return decoded, name, nil
+}
+
+func detectCharset(body []byte, mediaType, declared string) string {
+	for _, b := range boms {
+		if bytes.HasPrefix(body, b.bom) {
+			return b.charset
+		}
+	}
+
+	if enc, name := charset.Lookup(declared); enc != nil {
+		return name
+	}
+
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" || len(mediaType) == 0 {
+		if name := metaCharset(body); len(name) > 0 {
+			return name
+		}
+	}
+
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+
+	return fallbackCharset
+}
+
+// metaCharset returns the charset declared by a meta tag at the beginning
+// of an HTML document, if any
+func metaCharset(body []byte) string {
+	if len(body) > maxMetaPrescan {
+		body = body[:maxMetaPrescan]
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, hasAttr := z.TagName()
+			if string(tag) != "meta" {
+				continue
+			}
+
+			var httpEquiv, content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+
+				switch string(key) {
+				case "charset":
+					if enc, name := charset.Lookup(string(val)); enc != nil {
+						return name
+					}
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(val))
+				case "content":
+					content = string(val)
+				}
+			}
+
+			if httpEquiv != "content-type" {
+				continue
+			}
+			if _, params, err := mime.ParseMediaType(content); err == nil {
+				if enc, name := charset.Lookup(params["charset"]); enc != nil {
+					return name
+				}
+			}
+		}
+	}
+}
+
+func isText(mediaType string) bool {
+	switch {
+	case len(mediaType) == 0,
+		strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded":
+		return true
+	}
+
+	return false
+}
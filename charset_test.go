@@ -0,0 +1,111 @@
+package websitepoller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestConvertToUTF8(t *testing.T) {
+	a := assert.New(t)
+
+	encode := func(enc encoding.Encoding, s string) []byte {
+		b, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	const (
+		latin = "<p>Crème brûlée, 10€</p>"
+		jp    = "<p>価格は千円です</p>"
+	)
+	pages := map[string]struct {
+		contentType string
+		body        []byte
+	}{
+		"header": {
+			contentType: "text/html; charset=ISO-8859-1",
+			body:        encode(charmap.ISO8859_15, "<p>Crème brûlée</p>"),
+		},
+		"meta": {
+			contentType: "text/html",
+			body:        append([]byte(`<html><head><meta charset="windows-1252"></head>`), encode(charmap.Windows1252, latin)...),
+		},
+		"http-equiv": {
+			contentType: "text/html",
+			body:        append([]byte(`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`), encode(japanese.ShiftJIS, jp)...),
+		},
+		"bom": {
+			contentType: "text/plain",
+			body:        encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), jp),
+		},
+		"utf8": {
+			contentType: "application/json",
+			body:        []byte(`{"name":"Crème brûlée"}`),
+		},
+		"guess": {
+			contentType: "text/plain",
+			body:        encode(charmap.Windows1252, latin),
+		},
+		"binary": {
+			contentType: "image/png",
+			body:        []byte{0x89, 'P', 'N', 'G', 0xff},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := pages[r.URL.Path[1:]]
+		w.Header().Set("Content-Type", page.contentType)
+		w.Write(page.body)
+	}))
+	defer server.Close()
+
+	poll := func(name string) *Result {
+		p, err := New(&Page{URL: server.URL + "/" + name, ConvertToUTF8: true})
+		a.NoError(err)
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		a.NoError(res.Err)
+		return res
+	}
+
+	res := poll("header")
+	a.Equal("<p>Crème brûlée</p>", string(res.Body))
+	a.Equal("windows-1252", res.Charset)
+	a.Equal("text/html; charset=utf-8", res.Response.Header.Get("Content-Type"))
+
+	res = poll("meta")
+	a.Contains(string(res.Body), latin)
+	a.Equal("windows-1252", res.Charset)
+
+	res = poll("http-equiv")
+	a.Contains(string(res.Body), jp)
+	a.Equal("shift_jis", res.Charset)
+
+	res = poll("bom")
+	a.Equal(jp, string(res.Body))
+	a.Equal("utf-16le", res.Charset)
+
+	res = poll("utf8")
+	a.Equal(`{"name":"Crème brûlée"}`, string(res.Body))
+	a.Equal("utf-8", res.Charset)
+
+	res = poll("guess")
+	a.Equal(latin, string(res.Body))
+	a.Equal("windows-1252", res.Charset)
+
+	res = poll("binary")
+	a.Equal(pages["binary"].body, res.Body)
+	a.Empty(res.Charset)
+}
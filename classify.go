@@ -0,0 +1,162 @@
+package websitepoller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Classify returns the error of the result as a *PollError. If the request
+// succeeded, it returns a *PollError with kind ErrRateLimited or
+// ErrUnexpectedStatus if the status code of the response is a client or
+// server error, with kind ErrBodyTooLarge if the body was truncated, or nil
+// otherwise. Use it together with IsRetryable to decide whether to retry or
+// to alert.
+func Classify(res *Result) error {
+	if res.Err != nil {
+		return pollError(res.ID, res.Err)
+	}
+	if res.Response == nil {
+		return nil
+	}
+	if res.Response.StatusCode < 400 {
+		if res.Truncated {
+			return &PollError{ID: res.ID, Kind: ErrBodyTooLarge, Err: fmt.Errorf("truncated to %d bytes", len(res.Body))}
+		}
+		return nil
+	}
+
+	code := res.Response.StatusCode
+	status := res.Response.Status
+	if len(status) == 0 {
+		status = strconv.Itoa(code) + " " + http.StatusText(code)
+	}
+
+	err := &PollError{ID: res.ID, Kind: ErrUnexpectedStatus, StatusCode: code, Err: errors.New(status)}
+	retryAfter, hasRetryAfter := parseRetryAfter(res.Response.Header.Get("Retry-After"), time.Now())
+	if code == http.StatusTooManyRequests || (code == http.StatusServiceUnavailable && hasRetryAfter) {
+		err.Kind, err.RetryAfter = ErrRateLimited, retryAfter
+	}
+
+	return err
+}
+
+// IsRetryable returns true if err is the error of a poll that failed because
+// of a condition that is likely temporary: timeouts, refused connections,
+// DNS failures other than unknown hosts, rate limits and server errors.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pollErr *PollError
+	if !errors.As(err, &pollErr) {
+		pollErr = &PollError{Kind: errorKind(err), Err: err}
+	}
+
+	var dnsErr *net.DNSError
+	switch pollErr.Kind {
+	case ErrTimeout, ErrConnectionRefused, ErrRateLimited:
+		return true
+	case ErrDNS:
+		return !errors.As(err, &dnsErr) || !dnsErr.IsNotFound
+	case ErrUnexpectedStatus:
+		return pollErr.StatusCode >= 500
+	}
+
+	return false
+}
+
+// pollError wraps the error of a failed poll in a *PollError, unless it
+// already is one
+func pollError(id string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pollErr *PollError
+	if errors.As(err, &pollErr) {
+		return err
+	}
+
+	return &PollError{ID: id, Kind: errorKind(err), Err: err}
+}
+
+// errorKind returns the kind of the error of a request, or nil if it is
+// not known
+func errorKind(err error) error {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+
+	switch {
+	case errors.Is(err, ErrTooManyRedirects):
+		return ErrTooManyRedirects
+	case errors.Is(err, ErrBodyTooLarge):
+		return ErrBodyTooLarge
+	case errors.As(err, &dnsErr):
+		return ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnectionRefused
+	case isTLSError(err):
+		return ErrTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	}
+
+	return nil
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	return errors.Is(err, ErrPinnedKeyMismatch) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either in
+// seconds or as a date, and returns false if there is none
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+
+	return 0, true
+}
@@ -0,0 +1,182 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollErrors(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	poll := func(url string, timeout time.Duration) *PollError {
+		id := "failing"
+		p, err := New(&Page{ID: &id, URL: url, FollowRedirect: true})
+		a.NoError(err)
+		if timeout > 0 {
+			p.(*pagePoller).httpClient.Timeout = timeout
+		}
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+
+		var pollErr *PollError
+		a.True(errors.As(res.Err, &pollErr), url)
+		a.Equal(res.Err, Classify(res))
+		a.Equal("failing", pollErr.ID)
+		return pollErr
+	}
+
+	err := poll("http://127.0.0.1:1", 0)
+	a.True(errors.Is(err, ErrConnectionRefused))
+	a.True(errors.Is(err, syscall.ECONNREFUSED))
+	a.True(IsRetryable(err))
+	a.Contains(err.Error(), "poller failing: connection refused: ")
+
+	err = poll(server.URL+"/slow", 50*time.Millisecond)
+	a.True(errors.Is(err, ErrTimeout))
+	a.False(errors.Is(err, ErrConnectionRefused))
+	a.True(IsRetryable(err))
+
+	err = poll(server.URL+"/loop", 0)
+	a.True(errors.Is(err, ErrTooManyRedirects))
+	a.False(IsRetryable(err))
+
+	err = poll(tlsServer.URL, 0)
+	a.True(errors.Is(err, ErrTLS))
+	a.False(IsRetryable(err))
+}
+
+func TestErrorKind(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(ErrBodyTooLarge, errorKind(fmt.Errorf("%w: 10 bytes", ErrBodyTooLarge)))
+	a.Equal(ErrTLS, errorKind(fmt.Errorf("%w: sha256/...", ErrPinnedKeyMismatch)))
+	a.Equal(ErrTimeout, errorKind(context.DeadlineExceeded))
+	a.Nil(errorKind(errors.New("something else")))
+
+	// -- Unknown errors are wrapped anyway
+	err := pollError("id", ErrCaptureNotFound)
+	a.True(errors.Is(err, ErrCaptureNotFound))
+	a.Equal("poller id: captured value not found", err.Error())
+	a.Equal(err, pollError("other", err))
+	a.False(IsRetryable(err))
+	a.False(IsRetryable(nil))
+
+	// -- Also works on errors that are not wrapped
+	a.True(IsRetryable(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)))
+}
+
+func TestClassifyStatus(t *testing.T) {
+	a := assert.New(t)
+
+	classify := func(code int, retryAfter string) *PollError {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		if len(retryAfter) > 0 {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+
+		err := Classify(&Result{ID: "status", Response: resp})
+		if err == nil {
+			return nil
+		}
+		return err.(*PollError)
+	}
+
+	a.Nil(classify(http.StatusOK, ""))
+	a.Nil(classify(http.StatusNotModified, ""))
+
+	err := classify(http.StatusTooManyRequests, "120")
+	a.True(errors.Is(err, ErrRateLimited))
+	a.Equal(http.StatusTooManyRequests, err.StatusCode)
+	a.Equal(2*time.Minute, err.RetryAfter)
+	a.True(IsRetryable(err))
+	a.Equal("poller status: rate limited: 429 Too Many Requests", err.Error())
+
+	err = classify(http.StatusServiceUnavailable, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	a.True(errors.Is(err, ErrRateLimited))
+	a.InDelta(time.Hour, err.RetryAfter, float64(2*time.Second))
+
+	err = classify(http.StatusServiceUnavailable, "")
+	a.True(errors.Is(err, ErrUnexpectedStatus))
+	a.True(IsRetryable(err))
+
+	err = classify(http.StatusNotFound, "")
+	a.True(errors.Is(err, ErrUnexpectedStatus))
+	a.False(errors.Is(err, ErrRateLimited))
+	a.False(IsRetryable(err))
+}
+
+func TestClassifyTruncated(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a body of 20 bytes.."))
+	}))
+	defer server.Close()
+
+	id := "large"
+	p, err := New(&Page{ID: &id, URL: server.URL, MaxBodySize: 10})
+	a.NoError(err)
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+
+	// -- Not a request error, but a failure
+	a.NoError(res.Err)
+	a.True(res.Truncated)
+	err = Classify(res)
+	a.True(errors.Is(err, ErrBodyTooLarge))
+	a.False(IsRetryable(err))
+	a.Equal("poller large: response body too large: truncated to 10 bytes", err.Error())
+	a.Equal(uint64(1), p.Stats().Failures)
+
+	res.Truncated = false
+	a.NoError(Classify(res))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	wait, ok := parseRetryAfter("", now)
+	a.False(ok)
+	a.Zero(wait)
+	_, ok = parseRetryAfter("soon", now)
+	a.False(ok)
+	_, ok = parseRetryAfter("-1", now)
+	a.False(ok)
+
+	wait, ok = parseRetryAfter(" 30 ", now)
+	a.True(ok)
+	a.Equal(30*time.Second, wait)
+
+	wait, ok = parseRetryAfter("Wed, 01 Jan 2020 00:01:00 GMT", now)
+	a.True(ok)
+	a.Equal(time.Minute, wait)
+
+	// -- Dates in the past mean right away
+	wait, ok = parseRetryAfter("Tue, 31 Dec 2019 00:00:00 GMT", now)
+	a.True(ok)
+	a.Zero(wait)
+}
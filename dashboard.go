@@ -0,0 +1,215 @@
+package websitepoller
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDashboardTitle   string        = "Pollers"
+	defaultDashboardRefresh time.Duration = 5 * time.Second
+	sparklineWidth          float64       = 120
+	sparklineHeight         float64       = 24
+)
+
+//go:embed assets/dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// DashboardOptions contains options about the dashboard
+type DashboardOptions struct {
+	// Title of the page. Defaults to "Pollers".
+	Title string
+	// Refresh is how often the page reloads itself. Defaults to 5 seconds.
+	Refresh time.Duration
+}
+
+type dashboard struct {
+	manager *Manager
+	opts    DashboardOptions
+	now     func() time.Time
+}
+
+// dashboardView is what the template renders
+type dashboardView struct {
+	Title          string
+	RefreshSeconds int
+	Generated      time.Time
+	Pollers        []dashboardPoller
+	Changes        []dashboardChange
+}
+
+type dashboardPoller struct {
+	ID          string
+	Method      string
+	URL         string
+	State       string
+	StatusCode  int
+	LastError   string
+	LastPoll    *time.Time
+	LatencyMS   float64
+	Sparkline   string
+	ErrorStreak int
+	NextPoll    string
+	Polls       uint64
+	Failures    uint64
+}
+
+type dashboardChange struct {
+	ID   string
+	Time time.Time
+	Hash string
+}
+
+// NewDashboardHandler returns an http.Handler that serves an HTML page with
+// the state of the pollers of the manager: last status code, latency of the
+// recent polls, failures in a row, time until the next poll and the changes
+// detected recently. The page is rendered on the server and reloads itself,
+// without any script. Use http.StripPrefix to mount it under a path.
+func NewDashboardHandler(m *Manager, opts DashboardOptions) http.Handler {
+	if len(opts.Title) == 0 {
+		opts.Title = defaultDashboardTitle
+	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = defaultDashboardRefresh
+	}
+
+	return &dashboard{manager: m, opts: opts, now: time.Now}
+}
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// -- Render first, so that errors do not end up in a half-written page
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, d.view()); err != nil {
+		log.Error().Err(err).Msg("could not render dashboard")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+func (d *dashboard) view() *dashboardView {
+	now := d.now()
+	view := &dashboardView{
+		Title:          d.opts.Title,
+		RefreshSeconds: int(d.opts.Refresh.Round(time.Second) / time.Second),
+		Generated:      now,
+	}
+	if view.RefreshSeconds < 1 {
+		view.RefreshSeconds = 1
+	}
+
+	for _, p := range d.manager.Pollers() {
+		status, page := p.Status(), p.Page()
+		method := http.MethodGet
+		if page.Method != nil && len(*page.Method) > 0 {
+			method = strings.ToUpper(*page.Method)
+		}
+
+		dp := dashboardPoller{
+			ID:          status.ID,
+			Method:      method,
+			URL:         redactURL(page.URL),
+			State:       pollerState(status),
+			ErrorStreak: status.Stats.ConsecutiveFailures,
+			NextPoll:    countdown(status, now),
+			Sparkline:   sparkline(status.Recent),
+			Polls:       status.Stats.Polls,
+			Failures:    status.Stats.Failures,
+		}
+		if len(status.Recent) > 0 {
+			last := status.Recent[0]
+			dp.StatusCode = last.StatusCode
+			dp.LastError = last.Error
+			dp.LastPoll = &last.Time
+			dp.LatencyMS = last.LatencyMS
+		}
+		view.Pollers = append(view.Pollers, dp)
+
+		for _, s := range status.Recent {
+			if s.Changed {
+				view.Changes = append(view.Changes, dashboardChange{ID: s.ID, Time: s.Time, Hash: s.Hash})
+			}
+		}
+	}
+
+	// -- Most recent first, across all pollers
+	sort.SliceStable(view.Changes, func(i, j int) bool {
+		return view.Changes[i].Time.After(view.Changes[j].Time)
+	})
+
+	return view
+}
+
+func pollerState(status Status) string {
+	switch {
+	case status.Stats.Disabled:
+		return "disabled"
+	case status.Paused:
+		return "paused"
+	case status.NextPoll == nil:
+		return "stopped"
+	default:
+		return "running"
+	}
+}
+
+// countdown returns the time left until the next poll, in a human readable
+// format
+func countdown(status Status, now time.Time) string {
+	if status.Stats.Disabled || status.Paused || status.NextPoll == nil {
+		return "-"
+	}
+
+	left := status.NextPoll.Sub(now).Round(time.Second)
+	if left <= 0 {
+		return "now"
+	}
+
+	return "in " + left.String()
+}
+
+// sparkline returns the points of an SVG polyline with the latencies of the
+// recent polls, oldest first. It is empty if there are less than two polls.
+func sparkline(recent []*Summary) string {
+	if len(recent) < 2 {
+		return ""
+	}
+
+	max := 0.0
+	for _, s := range recent {
+		if s.LatencyMS > max {
+			max = s.LatencyMS
+		}
+	}
+
+	points := make([]string, len(recent))
+	step := sparklineWidth / float64(len(recent)-1)
+	for i := range recent {
+		// -- Recent summaries are most recent first
+		s := recent[len(recent)-1-i]
+		y := sparklineHeight
+		if max > 0 {
+			y -= s.LatencyMS / max * sparklineHeight
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+
+	return strings.Join(points, " ")
+}
@@ -0,0 +1,274 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	diffContext = 3
+	// maxDiffEdits is the maximum number of edits computed by diffLines:
+	// beyond this, the whole text is reported as replaced.
+	maxDiffEdits = 2000
+)
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff between the two texts. It returns an
+// empty string if they are equal.
+func unifiedDiff(oldName, newName string, oldText, newText []byte) string {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	// -- Positions of each op in the old and new text
+	oldPos, newPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	changes := []int{}
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(changes); {
+		// -- Group changes that are close to each other in the same hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext {
+			j++
+		}
+
+		start, end := changes[i]-diffContext, changes[j]+diffContext+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[end]-oldPos[start]),
+			hunkRange(newPos[start], newPos[end]-newPos[start]))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script between a and b with the
+// Myers algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+
+	// -- Forward pass: trace[d][k+d] is the furthest x reached on diagonal
+	// k with d edits
+	trace := [][]int{}
+	prev := []int{0}
+	found := false
+	for d := 0; d <= n+m && d <= maxDiffEdits && !found; d++ {
+		curr := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]):
+				x = prev[k+1+d-1]
+			default:
+				x = prev[k-1+d-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			curr[k+d] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		trace = append(trace, curr)
+		prev = curr
+	}
+
+	if !found {
+		ops := make([]diffOp, 0, n+m)
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// -- Backtrack from the end to build the edit script
+	ops := []diffOp{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d-1]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+d-1] < v[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d-1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// jsonDiff returns a structural diff between two JSON documents, with one
+// line for each difference, e.g.:
+//
+//	~ $.items[0].price: 10 -> 12
+//	+ $.items[2]: {"price":5}
+//	- $.discount: true
+func jsonDiff(oldDoc, newDoc []byte) (string, error) {
+	var oldVal, newVal interface{}
+	if err := json.Unmarshal(oldDoc, &oldVal); err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(newDoc, &newVal); err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	diffJSONValues("$", oldVal, newVal, &lines)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func diffJSONValues(path string, oldVal, newVal interface{}, lines *[]string) {
+	switch o := oldVal.(type) {
+	case map[string]interface{}:
+		n, ok := newVal.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := []string{}
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, exists := o[k]; !exists {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "." + k
+			oldChild, inOld := o[k]
+			newChild, inNew := n[k]
+
+			switch {
+			case !inOld:
+				*lines = append(*lines, fmt.Sprintf("+ %s: %s", childPath, encodeJSON(newChild)))
+			case !inNew:
+				*lines = append(*lines, fmt.Sprintf("- %s: %s", childPath, encodeJSON(oldChild)))
+			default:
+				diffJSONValues(childPath, oldChild, newChild, lines)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := newVal.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(o) || i < len(n); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case i >= len(o):
+				*lines = append(*lines, fmt.Sprintf("+ %s: %s", childPath, encodeJSON(n[i])))
+			case i >= len(n):
+				*lines = append(*lines, fmt.Sprintf("- %s: %s", childPath, encodeJSON(o[i])))
+			default:
+				diffJSONValues(childPath, o[i], n[i], lines)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldVal, newVal) {
+		*lines = append(*lines, fmt.Sprintf("~ %s: %s -> %s", path, encodeJSON(oldVal), encodeJSON(newVal)))
+	}
+}
+
+func encodeJSON(v interface{}) string {
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
@@ -0,0 +1,81 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that can be written in YAML and JSON either
+// as a string parsed by time.ParseDuration, i.e. "1m30s" or "500ms", or as
+// a number of seconds, i.e. 90.
+type Duration time.Duration
+
+// parseDuration parses a duration string or, if it has no unit, a number of
+// seconds
+func parseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return Duration(seconds * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+
+	return Duration(d), nil
+}
+
+// String returns the duration formatted as by time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalYAML parses a duration string or a number of seconds
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	parsed, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML writes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalJSON parses a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s: must be a string or a number of seconds", data)
+		}
+		s = n.String()
+	}
+
+	parsed, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,51 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDurationYAML(t *testing.T) {
+	a := assert.New(t)
+
+	var opts PollOptions
+	a.NoError(yaml.Unmarshal([]byte(`
+frequency: 1m30s
+offsetRange: 500ms
+minFrequency: 0.25
+`), &opts))
+	a.Equal(Duration(90*time.Second), opts.Frequency)
+	a.Equal(Duration(500*time.Millisecond), *opts.OffsetRange)
+	a.Equal(Duration(250*time.Millisecond), opts.MinFrequency)
+
+	// -- Numbers are seconds
+	a.NoError(yaml.Unmarshal([]byte(`frequency: 45`), &opts))
+	a.Equal(Duration(45*time.Second), opts.Frequency)
+
+	a.Error(yaml.Unmarshal([]byte(`frequency: often`), &opts))
+
+	out, err := yaml.Marshal(&PollOptions{Frequency: Duration(90 * time.Second)})
+	a.NoError(err)
+	a.Contains(string(out), "frequency: 1m30s\n")
+}
+
+func TestDurationJSON(t *testing.T) {
+	a := assert.New(t)
+
+	var opts PollOptions
+	a.NoError(json.Unmarshal([]byte(`{"frequency": "2m", "offsetRange": 10, "minFrequency": 1.5}`), &opts))
+	a.Equal(Duration(2*time.Minute), opts.Frequency)
+	a.Equal(Duration(10*time.Second), *opts.OffsetRange)
+	a.Equal(Duration(1500*time.Millisecond), opts.MinFrequency)
+
+	a.Error(json.Unmarshal([]byte(`{"frequency": "often"}`), &opts))
+	a.Error(json.Unmarshal([]byte(`{"frequency": true}`), &opts))
+
+	out, err := json.Marshal(&PollOptions{Frequency: Duration(500 * time.Millisecond)})
+	a.NoError(err)
+	a.Contains(string(out), `"frequency":"500ms"`)
+}
@@ -0,0 +1,166 @@
+package websitepoller
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	contentEncodingHeaderKey string = "Content-Encoding"
+)
+
+// contentEncodings returns the encodings applied to the body of the
+// response, in the order they were applied. Nothing is returned if the
+// body is not encoded, was already decoded by the HTTP client or uses an
+// unsupported encoding, in which case the body is left untouched.
+func contentEncodings(resp *http.Response) []string {
+	if resp.Uncompressed {
+		return nil
+	}
+
+	encodings := []string{}
+	for _, val := range resp.Header.Values(contentEncodingHeaderKey) {
+		for _, enc := range strings.Split(val, ",") {
+			switch enc = strings.ToLower(strings.TrimSpace(enc)); enc {
+			case "", "identity":
+			case "gzip", "x-gzip", "deflate", "br", "zstd":
+				encodings = append(encodings, enc)
+			default:
+				return nil
+			}
+		}
+	}
+
+	return encodings
+}
+
+// decodeResponse replaces the body of the response with one that decodes
+// it and removes the headers that refer to the encoded body. It returns
+// the original value of the Content-Encoding header, or an empty string if
+// the body was not decoded.
+func decodeResponse(resp *http.Response) (string, error) {
+	encodings := contentEncodings(resp)
+	if len(encodings) == 0 || hasNoBody(resp) {
+		return "", nil
+	}
+
+	// -- Bodies of unknown length can be empty too, and decoders fail on
+	// empty bodies as they read their header right away
+	peeked := bufio.NewReader(resp.Body)
+	if _, err := peeked.Peek(1); err == io.EOF {
+		return "", nil
+	}
+
+	body, err := newDecoder(struct {
+		io.Reader
+		io.Closer
+	}{peeked, resp.Body}, encodings)
+	if err != nil {
+		return "", err
+	}
+
+	original := strings.Join(resp.Header.Values(contentEncodingHeaderKey), ", ")
+	resp.Body = body
+	resp.Header.Del(contentEncodingHeaderKey)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return original, nil
+}
+
+// hasNoBody returns true if the response has no body, whatever its headers
+// say, i.e. the response to a HEAD request or a 304
+func hasNoBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return true
+	}
+
+	switch code := resp.StatusCode; {
+	case code >= 100 && code < 200, code == http.StatusNoContent, code == http.StatusNotModified:
+		return true
+	}
+
+	return resp.ContentLength == 0
+}
+
+type decodingBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodingBody) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if cerr := d.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// newDecoder returns a reader that decodes body with the given encodings,
+// listed in the order they were applied. Closing it closes body.
+func newDecoder(body io.ReadCloser, encodings []string) (io.ReadCloser, error) {
+	d := &decodingBody{Reader: body, closers: []io.Closer{body}}
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(d.Reader)
+			if err != nil {
+				d.Close()
+				return nil, fmt.Errorf("could not decode gzip body: %w", err)
+			}
+			d.Reader = gz
+			d.closers = append(d.closers, gz)
+		case "deflate":
+			fl, err := newDeflateReader(d.Reader)
+			if err != nil {
+				d.Close()
+				return nil, fmt.Errorf("could not decode deflate body: %w", err)
+			}
+			d.Reader = fl
+			d.closers = append(d.closers, fl)
+		case "br":
+			d.Reader = brotli.NewReader(d.Reader)
+		case "zstd":
+			zr, err := zstd.NewReader(d.Reader)
+			if err != nil {
+				d.Close()
+				return nil, fmt.Errorf("could not decode zstd body: %w", err)
+			}
+			d.Reader = zr
+			d.closers = append(d.closers, zr.IOReadCloser())
+		}
+	}
+
+	return d, nil
+}
+
+// newDeflateReader returns a reader for deflate bodies. These should be
+// zlib streams, but many servers send raw deflate data instead, so both are
+// accepted.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,150 @@
+package websitepoller
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeBody(t *testing.T, encoding string, body []byte) []byte {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write(body)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompression(t *testing.T) {
+	a := assert.New(t)
+	content := []byte(`<html><body><span class="price">10</span></body></html>`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		switch enc := r.URL.Query().Get("enc"); enc {
+		case "":
+		case "compress":
+			w.Header().Set("Content-Encoding", enc)
+		case "raw-deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			body = encodeBody(t, enc, body)
+		case "gzip, br":
+			w.Header().Set("Content-Encoding", enc)
+			body = encodeBody(t, "br", encodeBody(t, "gzip", body))
+		default:
+			w.Header().Set("Content-Encoding", enc)
+			body = encodeBody(t, enc, body)
+		}
+
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	poll := func(enc string) *Result {
+		p, err := New(&Page{
+			URL: server.URL + "?enc=" + url.QueryEscape(enc),
+			Headers: Headers{
+				{Key: "Accept-Encoding", Values: []string{"gzip, deflate, br, zstd"}},
+			},
+			Extractors: []Extractor{{Name: "price", CSS: "span.price"}},
+		})
+		a.NoError(err)
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		return res
+	}
+
+	for _, enc := range []string{"", "gzip", "deflate", "raw-deflate", "br", "zstd", "gzip, br"} {
+		res := poll(enc)
+		a.NoError(res.Err, enc)
+		a.Equal(content, res.Body, enc)
+		a.Equal("10", res.Fields["price"], enc)
+		a.Empty(res.Response.Header.Get("Content-Encoding"), enc)
+
+		switch enc {
+		case "raw-deflate":
+			a.Equal("deflate", res.ContentEncoding)
+		default:
+			a.Equal(enc, res.ContentEncoding)
+		}
+	}
+
+	// -- Unsupported encodings are left untouched
+	res := poll("compress")
+	a.NoError(res.Err)
+	a.Empty(res.ContentEncoding)
+	a.Equal("compress", res.Response.Header.Get("Content-Encoding"))
+}
+
+func TestDecompressionWithoutBody(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		switch r.URL.Path {
+		case "/204":
+			w.WriteHeader(http.StatusNoContent)
+		case "/304":
+			w.WriteHeader(http.StatusNotModified)
+		case "/empty":
+			w.Header().Set("Content-Length", "0")
+		case "/chunked":
+			w.(http.Flusher).Flush()
+		default:
+			w.Write(encodeBody(t, "gzip", []byte("content")))
+		}
+	}))
+	defer server.Close()
+
+	head := http.MethodHead
+	for _, page := range []*Page{
+		{URL: server.URL, Method: &head},
+		{URL: server.URL + "/204"},
+		{URL: server.URL + "/304"},
+		{URL: server.URL + "/empty"},
+		{URL: server.URL + "/chunked"},
+	} {
+		page.Headers = Headers{{Key: "Accept-Encoding", Values: []string{"gzip"}}}
+		p, err := New(page)
+		a.NoError(err)
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		a.NoError(res.Err, page.URL)
+		a.Empty(res.Body, page.URL)
+		a.Empty(res.ContentEncoding, page.URL)
+	}
+}
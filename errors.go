@@ -1,19 +1,166 @@
 package websitepoller
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	// ErrUnrecognizedHTTPMethod means that the http method is not recognized
 	ErrUnrecognizedHTTPMethod = errors.New("unrecognized http method")
 	// ErrURLNoScheme means that the url has no http:// or https://
 	ErrURLNoScheme = errors.New("url does not have a scheme")
-	// ErrInvalidFrequency means that the polling frequency is invalid, i.e.
-	// if it could not be parsed by time.ParseDuration
+	// ErrInvalidFrequency means that the polling frequency or the minimum
+	// frequency is invalid, i.e. negative
 	ErrInvalidFrequency = errors.New("invalid polling frequency")
 	// ErrUnsupportedFrequency means that the polling frequency is lower
-	// than five second
-	ErrUnsupportedFrequency = errors.New("frequency cannot be lower than five second")
-	// ErrInvalidRandRange is thrown when the range could not be parsed by
-	// time.ParseDuration or is greater or equal than frequency
+	// than the minimum frequency, five seconds by default
+	ErrUnsupportedFrequency = errors.New("frequency is lower than the minimum")
+	// ErrInvalidRandRange is thrown when the range is lower than the
+	// minimum, or too large for the frequency
 	ErrInvalidRandRange = errors.New("invalid range")
+	// ErrInvalidExtractor means that an extractor has no name, has a
+	// duplicate name, or does not define exactly one expression
+	ErrInvalidExtractor = errors.New("invalid extractor")
+	// ErrInvalidJSONPath means that a JSONPath expression could not be parsed
+	ErrInvalidJSONPath = errors.New("invalid jsonpath expression")
+	// ErrUnknownChangeField means that change detection refers to a field
+	// that is not defined by any extractor
+	ErrUnknownChangeField = errors.New("unknown change detection field")
+	// ErrInvalidStep means that a step has no name, has a duplicate name or
+	// an invalid capture
+	ErrInvalidStep = errors.New("invalid step")
+	// ErrInvalidTemplate means that a value contains a malformed variable,
+	// i.e. an unclosed ${
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrUnknownVariable means that a template references a variable that
+	// is not defined
+	ErrUnknownVariable = errors.New("unknown variable")
+	// ErrCaptureNotFound means that the value to capture from the response
+	// of a step could not be found
+	ErrCaptureNotFound = errors.New("captured value not found")
+	// ErrInvalidAuth means that the authentication options define no
+	// provider or more than one, or a provider is missing required values
+	ErrInvalidAuth = errors.New("invalid authentication options")
+	// ErrInvalidSecret means that a secret defines neither or both of an
+	// environment variable and a file, or the environment variable is not
+	// set
+	ErrInvalidSecret = errors.New("invalid secret")
+	// ErrInvalidTLSOptions means that the TLS options contain an invalid
+	// value, i.e. an unsupported version or a malformed pinned key
+	ErrInvalidTLSOptions = errors.New("invalid tls options")
+	// ErrInvalidTransportOptions means that the transport options contain
+	// an unsupported HTTP version or a negative limit or timeout
+	ErrInvalidTransportOptions = errors.New("invalid transport options")
+	// ErrPinnedKeyMismatch means that no certificate presented by the
+	// server has one of the pinned public keys
+	ErrPinnedKeyMismatch = errors.New("no pinned public key matched the server's certificates")
+	// ErrInvalidSnapshotOptions means that the snapshot options have no
+	// directory or an unsupported diff format
+	ErrInvalidSnapshotOptions = errors.New("invalid snapshot options")
+	// ErrInvalidRedirectPolicy means that the redirect policy has negative
+	// max hops, an empty allowed domain or an unsupported method rule
+	ErrInvalidRedirectPolicy = errors.New("invalid redirect policy")
+	// ErrInvalidSinkOptions means that the options of a sink are missing a
+	// required value, i.e. the path of the file sink
+	ErrInvalidSinkOptions = errors.New("invalid sink options")
+	// ErrBodyTooLarge means that the body of the response is larger than
+	// the allowed size
+	ErrBodyTooLarge = errors.New("response body too large")
+	// ErrDuplicatePoller means that a poller with the same ID has already
+	// been added
+	ErrDuplicatePoller = errors.New("duplicate poller id")
+	// ErrPollerNotFound means that there is no poller with the given ID
+	ErrPollerNotFound = errors.New("poller not found")
+	// ErrPageNotAllowed means that a page submitted through the admin API
+	// was rejected, i.e. because it reads local files
+	ErrPageNotAllowed = errors.New("page not allowed")
+	// ErrPollInProgress means that a poll requested through the admin API
+	// is still running
+	ErrPollInProgress = errors.New("poll already in progress")
+	// ErrTimeout means that the request timed out, either while connecting
+	// or while waiting for the response
+	ErrTimeout = errors.New("timeout")
+	// ErrDNS means that the host of the page could not be resolved
+	ErrDNS = errors.New("dns failure")
+	// ErrConnectionRefused means that the server refused the connection
+	ErrConnectionRefused = errors.New("connection refused")
+	// ErrTLS means that the TLS handshake failed, i.e. because the
+	// certificate of the server could not be verified
+	ErrTLS = errors.New("tls error")
+	// ErrTooManyRedirects means that the request was redirected more times
+	// than allowed
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrRateLimited means that the server responded with 429 Too Many
+	// Requests, or with 503 Service Unavailable and a Retry-After header
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnexpectedStatus means that the server responded with a client or
+	// server error status code
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// ErrPollerDisabled means that the poller was disabled because too
+	// many consecutive polls panicked
+	ErrPollerDisabled = errors.New("poller disabled")
 )
+
+// PanicError is the error reported when a poll panics, i.e. in the handler
+type PanicError struct {
+	// ID of the poller
+	ID string
+	// Value passed to panic
+	Value interface{}
+	// Stack of the goroutine that panicked
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("poller %s panicked: %v", e.ID, e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// PollError is the error of a failed poll. Its Kind is one of ErrTimeout,
+// ErrDNS, ErrConnectionRefused, ErrTLS, ErrTooManyRedirects,
+// ErrBodyTooLarge, ErrRateLimited and ErrUnexpectedStatus, or nil if the
+// failure could not be classified. Check it with errors.Is, i.e.
+// errors.Is(err, ErrTimeout), and get the cause with errors.Unwrap.
+type PollError struct {
+	// ID of the poller
+	ID string
+	// Kind of failure
+	Kind error
+	// StatusCode of the response, for ErrRateLimited and
+	// ErrUnexpectedStatus
+	StatusCode int
+	// RetryAfter is how long the server asked to wait before the next
+	// request, if it did
+	RetryAfter time.Duration
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *PollError) Error() string {
+	msg := "poller " + e.ID
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+// Is returns true if target is the kind of the error
+func (e *PollError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap returns the underlying cause
+func (e *PollError) Unwrap() error {
+	return e.Err
+}
@@ -6,10 +6,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 
 	poller "github.com/SunSince90/website-poller"
@@ -31,13 +29,14 @@ func main() {
 
 	// -- Set up the pollers
 	var pages []poller.Page
-	var pollers []poller.Poller
 	err = yaml.Unmarshal(yamlFile, &pages)
 	if err != nil {
 		fmt.Println("error while unmarshalling file:", err)
 		os.Exit(1)
 	}
 
+	manager := poller.NewManager()
+
 	for _, page := range pages {
 		p, err := poller.New(&page)
 		if err != nil {
@@ -46,22 +45,19 @@ func main() {
 		}
 
 		p.SetHandlerFunc(handleResponse)
-		pollers = append(pollers, p)
+		if err := manager.Add(p); err != nil {
+			fmt.Println("could not add page:", err, ", skipping...")
+		}
 	}
 
 	// -- Start the pollers
 	ctx, canc := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
-	wg.Add(len(pollers))
-
-	for _, p := range pollers {
+	exitChan := make(chan struct{})
 
-		go func(currentPoller poller.Poller) {
-			defer wg.Done()
-			currentPoller.Start(ctx, true)
-		}(p)
-
-	}
+	go func() {
+		manager.Start(ctx, true)
+		close(exitChan)
+	}()
 
 	// -- Graceful shutdown
 	signalChan := make(chan os.Signal, 1)
@@ -74,11 +70,11 @@ func main() {
 
 	<-signalChan
 	fmt.Println("exit requested")
-	canc()    // Cancel all the pollers
-	wg.Wait() // Wait for all the pollers to finish before exiting!
+	canc()      // Cancel all the pollers
+	<-exitChan // Wait for all the pollers to finish before exiting!
 	fmt.Println("goodbye!")
 }
 
-func handleResponse(id string, resp *http.Response, err error) {
+func handleResponse(res *poller.Result) {
 	// Scrape the website...
 }
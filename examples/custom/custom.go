@@ -5,10 +5,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	poller "github.com/SunSince90/website-poller"
 )
@@ -17,15 +17,15 @@ import (
 
 func main() {
 	id := "poll-user"
-	offsetRange := 5
+	offsetRange := poller.Duration(5 * time.Second)
 	page := &poller.Page{
 		ID:  &id,
 		URL: "https://api.github.com/users/sunsince90",
-		Headers: map[string][]string{
-			"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"},
-			"Accept-Encoding": {"gzip, deflate, br"},
-			"Accept-Language": {"en-US,it-IT;q=0.8,it;q=0.5,en;q=0.3"},
-			"Cache-Control":   {"no-cache"},
+		Headers: poller.Headers{
+			{Key: "Accept", Values: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"}},
+			{Key: "Accept-Encoding", Values: []string{"gzip, deflate, br"}},
+			{Key: "Accept-Language", Values: []string{"en-US,it-IT;q=0.8,it;q=0.5,en;q=0.3"}},
+			{Key: "Cache-Control", Values: []string{"no-cache"}},
 		},
 		UserAgentOptions: &poller.UserAgentOptions{
 			UserAgents: []string{
@@ -37,9 +37,9 @@ func main() {
 			RandomUA: false, // rotate them at each request
 		},
 		PollOptions: &poller.PollOptions{
-			Frequency:       20,           // poll every 20 seconds
-			RandomFrequency: true,         // mimick user behavior: don't make requests at a fixed time
-			OffsetRange:     &offsetRange, // offsetRange is 5, so the range of requests is [15 - 25] seconds
+			Frequency:       poller.Duration(20 * time.Second), // poll every 20 seconds
+			RandomFrequency: true,                              // mimick user behavior: don't make requests at a fixed time
+			OffsetRange:     &offsetRange,                      // offsetRange is 5s, so the range of requests is [15s - 25s]
 		},
 	}
 
@@ -74,6 +74,11 @@ func main() {
 	fmt.Println("goodbye!")
 }
 
-func handleResponse(id string, resp *http.Response, err error) {
-	fmt.Println("request with id", id, "returned status", resp.Status)
+func handleResponse(res *poller.Result) {
+	if res.Err != nil {
+		fmt.Println("request with id", res.ID, "returned error", res.Err)
+		return
+	}
+
+	fmt.Println("request with id", res.ID, "returned status", res.Response.Status)
 }
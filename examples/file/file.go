@@ -6,7 +6,6 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -63,6 +62,11 @@ func main() {
 	fmt.Println("goodbye!")
 }
 
-func handleResponse(id string, resp *http.Response, err error) {
-	fmt.Println("request with id", id, "returned status", resp.Status)
+func handleResponse(res *poller.Result) {
+	if res.Err != nil {
+		fmt.Println("request with id", res.ID, "returned error", res.Err)
+		return
+	}
+
+	fmt.Println("request with id", res.ID, "returned status", res.Response.Status)
 }
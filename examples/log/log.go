@@ -5,7 +5,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -22,11 +21,11 @@ func main() {
 	page := &poller.Page{
 		ID:  &id,
 		URL: "https://api.github.com/users/sunsince90",
-		Headers: map[string][]string{
-			"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"},
-			"Accept-Encoding": {"gzip, deflate, br"},
-			"Accept-Language": {"en-US,it-IT;q=0.8,it;q=0.5,en;q=0.3"},
-			"Cache-Control":   {"no-cache"},
+		Headers: poller.Headers{
+			{Key: "Accept", Values: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"}},
+			{Key: "Accept-Encoding", Values: []string{"gzip, deflate, br"}},
+			{Key: "Accept-Language", Values: []string{"en-US,it-IT;q=0.8,it;q=0.5,en;q=0.3"}},
+			{Key: "Cache-Control", Values: []string{"no-cache"}},
 		},
 	}
 
@@ -61,6 +60,11 @@ func main() {
 	fmt.Println("goodbye!")
 }
 
-func handleResponse(id string, resp *http.Response, err error) {
-	fmt.Println("request with id", id, "returned status", resp.Status)
+func handleResponse(res *poller.Result) {
+	if res.Err != nil {
+		fmt.Println("request with id", res.ID, "returned error", res.Err)
+		return
+	}
+
+	fmt.Println("request with id", res.ID, "returned status", res.Response.Status)
 }
@@ -0,0 +1,184 @@
+package websitepoller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+type extractor struct {
+	name     string
+	attr     string
+	css      cascadia.Sel
+	xpath    *xpath.Expr
+	jsonPath *jsonPath
+	regex    *regexp.Regexp
+}
+
+// parseExtractors validates the extractors and returns them. All problems
+// are returned in a *ValidationError, under the index of their extractor.
+func parseExtractors(exts []Extractor) ([]*extractor, error) {
+	v := &validator{}
+	parsed := make([]*extractor, 0, len(exts))
+	names := map[string]bool{}
+
+	for i, ext := range exts {
+		path := indexPath(i)
+		if len(ext.Name) == 0 || names[ext.Name] {
+			v.check(joinPath(path, "name"), fmt.Errorf("%w: missing or duplicate name %q", ErrInvalidExtractor, ext.Name))
+		}
+		names[ext.Name] = true
+
+		e, err := parseExtractor(ext)
+		if v.check(path, err) {
+			parsed = append(parsed, e)
+		}
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// parseExtractor compiles the expression of the extractor. Its name is not
+// checked.
+func parseExtractor(ext Extractor) (*extractor, error) {
+	exprs := 0
+	for _, expr := range []string{ext.CSS, ext.XPath, ext.JSONPath, ext.Regex} {
+		if len(expr) > 0 {
+			exprs++
+		}
+	}
+	if exprs != 1 {
+		return nil, fmt.Errorf("%w: %s must define exactly one expression", ErrInvalidExtractor, ext.Name)
+	}
+
+	e := &extractor{name: ext.Name, attr: ext.Attribute}
+	var (
+		field string
+		err   error
+	)
+	switch {
+	case len(ext.CSS) > 0:
+		field = "css"
+		e.css, err = cascadia.Parse(ext.CSS)
+	case len(ext.XPath) > 0:
+		field = "xpath"
+		e.xpath, err = xpath.Compile(ext.XPath)
+	case len(ext.JSONPath) > 0:
+		field = "jsonPath"
+		e.jsonPath, err = parseJSONPath(ext.JSONPath)
+	default:
+		field = "regex"
+		e.regex, err = regexp.Compile(ext.Regex)
+	}
+	if err != nil {
+		v := &validator{}
+		v.check(field, fmt.Errorf("%w: %s: %v", ErrInvalidExtractor, ext.Name, err))
+		return nil, v.err()
+	}
+
+	return e, nil
+}
+
+// extractFields runs all extractors on body. The body is parsed as HTML or
+// JSON only once and only if at least one extractor needs it.
+func extractFields(id string, exts []*extractor, body []byte) map[string]string {
+	l := log.With().Str("id", id).Logger()
+	fields := map[string]string{}
+
+	var (
+		doc     *html.Node
+		docErr  error
+		data    interface{}
+		dataErr error
+		parsedH bool
+		parsedJ bool
+	)
+
+	for _, e := range exts {
+		var (
+			val string
+			ok  bool
+		)
+
+		switch {
+		case e.css != nil || e.xpath != nil:
+			if !parsedH {
+				doc, docErr = html.Parse(bytes.NewReader(body))
+				parsedH = true
+			}
+			if docErr != nil {
+				l.Warn().Err(docErr).Str("field", e.name).Msg("could not parse body as html")
+				continue
+			}
+
+			var node *html.Node
+			if e.css != nil {
+				node = cascadia.Query(doc, e.css)
+			} else {
+				node = htmlquery.QuerySelector(doc, e.xpath)
+			}
+			val, ok = nodeValue(node, e.attr)
+		case e.jsonPath != nil:
+			if !parsedJ {
+				dataErr = json.Unmarshal(body, &data)
+				parsedJ = true
+			}
+			if dataErr != nil {
+				l.Warn().Err(dataErr).Str("field", e.name).Msg("could not parse body as json")
+				continue
+			}
+
+			val, ok = e.jsonPath.evaluate(data)
+		default:
+			val, ok = regexValue(e.regex, body)
+		}
+
+		if ok {
+			fields[e.name] = val
+		}
+	}
+
+	return fields
+}
+
+func nodeValue(node *html.Node, attr string) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+
+	if len(attr) == 0 {
+		return strings.TrimSpace(htmlquery.InnerText(node)), true
+	}
+
+	for _, a := range node.Attr {
+		if a.Key == attr {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
+
+func regexValue(re *regexp.Regexp, body []byte) (string, bool) {
+	match := re.FindSubmatch(body)
+	if match == nil {
+		return "", false
+	}
+
+	if len(match) > 1 {
+		return string(match[1]), true
+	}
+
+	return string(match[0]), true
+}
@@ -0,0 +1,121 @@
+package websitepoller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExtractors(t *testing.T) {
+	a := assert.New(t)
+
+	cases := []struct {
+		arg    []Extractor
+		expErr bool
+	}{
+		{},
+		{
+			arg: []Extractor{{Name: "price", CSS: "div.price"}},
+		},
+		{
+			arg:    []Extractor{{CSS: "div.price"}},
+			expErr: true,
+		},
+		{
+			arg:    []Extractor{{Name: "price"}},
+			expErr: true,
+		},
+		{
+			arg:    []Extractor{{Name: "price", CSS: "div", Regex: "[0-9]+"}},
+			expErr: true,
+		},
+		{
+			arg:    []Extractor{{Name: "price", CSS: "div"}, {Name: "price", Regex: "[0-9]+"}},
+			expErr: true,
+		},
+		{
+			arg:    []Extractor{{Name: "price", XPath: "//div[@"}},
+			expErr: true,
+		},
+		{
+			arg:    []Extractor{{Name: "price", JSONPath: "items[0]"}},
+			expErr: true,
+		},
+	}
+
+	for i, currCase := range cases {
+		exts, err := parseExtractors(currCase.arg)
+
+		if currCase.expErr {
+			a.True(errors.Is(err, ErrInvalidExtractor), fmt.Sprintf("case %d failed", i))
+		} else {
+			a.NoError(err, fmt.Sprintf("case %d failed", i))
+			a.Len(exts, len(currCase.arg))
+		}
+	}
+}
+
+func TestExtractFields(t *testing.T) {
+	a := assert.New(t)
+
+	htmlBody := []byte(`<html><body>
+		<div class="price"><span> 12.50 </span></div>
+		<a id="next" href="/page/2">next</a>
+	</body></html>`)
+	jsonBody := []byte(`{"items": [{"price": 10, "name": "one"}, {"price": 20, "name": "two"}], "store": "main"}`)
+
+	exts, err := parseExtractors([]Extractor{
+		{Name: "css", CSS: "div.price > span"},
+		{Name: "cssAttr", CSS: "a#next", Attribute: "href"},
+		{Name: "xpath", XPath: "//div[@class='price']/span"},
+		{Name: "xpathAttr", XPath: "//a/@href"},
+		{Name: "regex", Regex: `([0-9]+\.[0-9]+)`},
+		{Name: "missing", CSS: "div.missing"},
+	})
+	a.NoError(err)
+	a.Equal(map[string]string{
+		"css":       "12.50",
+		"cssAttr":   "/page/2",
+		"xpath":     "12.50",
+		"xpathAttr": "/page/2",
+		"regex":     "12.50",
+	}, extractFields("", exts, htmlBody))
+
+	exts, err = parseExtractors([]Extractor{
+		{Name: "store", JSONPath: "$.store"},
+		{Name: "first", JSONPath: "$.items[0].name"},
+		{Name: "last", JSONPath: "$['items'][-1]['price']"},
+		{Name: "all", JSONPath: "$.items[*].price"},
+		{Name: "object", JSONPath: "$.items[1]"},
+		{Name: "missing", JSONPath: "$.items[5]"},
+	})
+	a.NoError(err)
+	a.Equal(map[string]string{
+		"store":  "main",
+		"first":  "one",
+		"last":   "20",
+		"all":    "[10,20]",
+		"object": `{"name":"two","price":20}`,
+	}, extractFields("", exts, jsonBody))
+
+	// A body that is not json produces no fields
+	a.Empty(extractFields("", exts, htmlBody))
+}
+
+func TestContentHash(t *testing.T) {
+	a := assert.New(t)
+
+	body := []byte("body")
+	a.Equal(contentHash(body, nil, nil), contentHash(body, map[string]string{"a": "1"}, nil))
+	a.NotEqual(contentHash(body, nil, nil), contentHash([]byte("other"), nil, nil))
+
+	fields := []string{"a"}
+	a.Equal(contentHash(body, map[string]string{"a": "1", "b": "2"}, fields), contentHash([]byte("other"), map[string]string{"a": "1", "b": "3"}, fields))
+	a.NotEqual(contentHash(body, map[string]string{"a": "1"}, fields), contentHash(body, map[string]string{"a": "2"}, fields))
+	a.NotEqual(contentHash(body, map[string]string{"a": ""}, fields), contentHash(body, map[string]string{}, fields))
+
+	_, _, err := parseChangeDetectionOptions(&ChangeDetectionOptions{Fields: []string{"unknown"}}, nil)
+	a.True(errors.Is(err, ErrUnknownChangeField))
+}
@@ -0,0 +1,175 @@
+package websitepoller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Headers is a list of headers, sent in the order they are defined. In YAML
+// and JSON, it is written as a map whose values can either be a string or a
+// list of strings, e.g.:
+//
+//	headers:
+//	  Accept: text/html
+//	  Accept-Language: [en-US, en]
+//	  User-Agent: null
+//
+// A header with no values, i.e. null or an empty list, is deleted from the
+// request: this is useful to prevent default headers from being sent.
+type Headers []Header
+
+// Header is a header with its values
+type Header struct {
+	// Key of the header, e.g.: Accept
+	Key string
+	// Values of the header. If empty, the header is deleted from the
+	// request.
+	Values []string
+}
+
+// HeaderFunc represents a function that is called with the ID of the
+// poller and the headers of each request, right before it is sent, so that
+// headers can be added, overridden or deleted on each poll.
+type HeaderFunc func(string, http.Header)
+
+// Keys returns the keys of the headers, in order
+func (h Headers) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for _, header := range h {
+		keys = append(keys, header.Key)
+	}
+
+	return keys
+}
+
+// UnmarshalYAML parses headers from a YAML map, preserving their order
+func (h *Headers) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ms yaml.MapSlice
+	if err := unmarshal(&ms); err != nil {
+		return err
+	}
+
+	headers := make(Headers, 0, len(ms))
+	for _, item := range ms {
+		key := fmt.Sprint(item.Key)
+
+		var values []string
+		switch v := item.Value.(type) {
+		case nil:
+		case []interface{}:
+			for _, val := range v {
+				values = append(values, fmt.Sprint(val))
+			}
+		case yaml.MapSlice:
+			return fmt.Errorf("header %s: value must be a string or a list of strings", key)
+		default:
+			values = []string{fmt.Sprint(v)}
+		}
+
+		headers = append(headers, Header{Key: key, Values: values})
+	}
+
+	*h = headers
+	return nil
+}
+
+// MarshalYAML returns headers as a YAML map, preserving their order
+func (h Headers) MarshalYAML() (interface{}, error) {
+	ms := make(yaml.MapSlice, 0, len(h))
+	for _, header := range h {
+		ms = append(ms, yaml.MapItem{Key: header.Key, Value: headerValue(header.Values)})
+	}
+
+	return ms, nil
+}
+
+// UnmarshalJSON parses headers from a JSON object, preserving their order
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*h = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("headers must be an object")
+	}
+
+	headers := Headers{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		var values []string
+		var single string
+		switch {
+		case bytes.Equal(raw, []byte("null")):
+		case json.Unmarshal(raw, &single) == nil:
+			values = []string{single}
+		case json.Unmarshal(raw, &values) == nil:
+		default:
+			return fmt.Errorf("header %s: value must be a string or a list of strings", key)
+		}
+
+		headers = append(headers, Header{Key: key, Values: values})
+	}
+
+	*h = headers
+	return nil
+}
+
+// MarshalJSON returns headers as a JSON object, preserving their order
+func (h Headers) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, header := range h {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(header.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(headerValue(header.Values))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// headerValue returns a single value as a string, so that marshalled
+// headers look like the ones written by hand.
+func headerValue(values []string) interface{} {
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	default:
+		return values
+	}
+}
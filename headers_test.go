@@ -0,0 +1,202 @@
+package websitepoller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestHeadersUnmarshal(t *testing.T) {
+	a := assert.New(t)
+	expected := Headers{
+		{Key: "Accept", Values: []string{"text/html"}},
+		{Key: "Accept-Language", Values: []string{"en-US", "en"}},
+		{Key: "User-Agent"},
+		{Key: "Cache-Control", Values: []string{"no-cache"}},
+	}
+
+	var page Page
+	err := yaml.Unmarshal([]byte(`
+url: https://example.com
+headers:
+  Accept: text/html
+  Accept-Language: [en-US, en]
+  User-Agent: null
+  Cache-Control: no-cache
+`), &page)
+	a.NoError(err)
+	a.Equal(expected, page.Headers)
+
+	marshalled, err := yaml.Marshal(page.Headers)
+	a.NoError(err)
+	a.Equal("Accept: text/html\nAccept-Language:\n- en-US\n- en\nUser-Agent: null\nCache-Control: no-cache\n", string(marshalled))
+
+	var headers Headers
+	err = json.Unmarshal([]byte(`{"Accept": "text/html", "Accept-Language": ["en-US", "en"], "User-Agent": null, "Cache-Control": "no-cache"}`), &headers)
+	a.NoError(err)
+	a.Equal(expected, headers)
+
+	encoded, err := json.Marshal(headers)
+	a.NoError(err)
+	a.Equal(`{"Accept":"text/html","Accept-Language":["en-US","en"],"User-Agent":null,"Cache-Control":"no-cache"}`, string(encoded))
+
+	a.Error(json.Unmarshal([]byte(`{"Accept": {"a": 1}}`), &headers))
+	a.Error(yaml.Unmarshal([]byte(`Accept: {a: 1}`), &headers))
+}
+
+func TestRequestHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	p, err := New(&Page{
+		URL: server.URL,
+		Headers: Headers{
+			{Key: "Accept-Language", Values: []string{"en-US", "en"}},
+			{Key: "User-Agent"},
+			{Key: "X-Remove", Values: []string{"me"}},
+		},
+	})
+	a.NoError(err)
+	p.SetHeaderFunc(func(id string, h http.Header) {
+		h.Del("X-Remove")
+		h.Set("X-Poll", "1")
+	})
+	p.(*pagePoller).poll(context.Background())
+
+	a.Equal([]string{"en-US", "en"}, received.Values("Accept-Language"))
+	a.Equal("1", received.Get("X-Poll"))
+	a.NotContains(received, "X-Remove")
+	a.NotContains(received, "User-Agent")
+}
+
+func TestPreserveHeaderOrder(t *testing.T) {
+	a := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	defer listener.Close()
+
+	heads := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		lines := []string{}
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || line == "\r\n" {
+				break
+			}
+			lines = append(lines, strings.SplitN(line, ":", 2)[0])
+		}
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+		heads <- lines[1:]
+	}()
+
+	p, err := New(&Page{
+		URL: "http://" + listener.Addr().String(),
+		Headers: Headers{
+			{Key: "User-Agent", Values: []string{"test"}},
+			{Key: "Accept", Values: []string{"*/*"}},
+			{Key: "Zzz", Values: []string{"z"}},
+			{Key: "Accept-Language", Values: []string{"en"}},
+		},
+		PreserveHeaderOrder: true,
+	})
+	a.NoError(err)
+	p.(*pagePoller).poll(context.Background())
+
+	head := <-heads
+	a.Equal([]string{"Host", "User-Agent", "Accept", "Zzz", "Accept-Language"}, head[:5])
+}
+
+func TestPreserveStepHeaderOrder(t *testing.T) {
+	a := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	defer listener.Close()
+
+	heads := make(chan []string, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			lines := []string{}
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == "\r\n" {
+					break
+				}
+				lines = append(lines, strings.SplitN(line, ":", 2)[0])
+			}
+			conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+			conn.Close()
+			heads <- lines[1:]
+		}
+	}()
+
+	headers := Headers{
+		{Key: "Zzz", Values: []string{"z"}},
+		{Key: "Accept", Values: []string{"*/*"}},
+	}
+	url := "http://" + listener.Addr().String()
+	p, err := New(&Page{
+		URL:     url,
+		Headers: headers,
+		Steps:   []Step{{Name: "login", URL: url + "/login", Headers: headers, PreserveHeaderOrder: true}},
+	})
+	a.NoError(err)
+	p.SetHandlerFunc(func(r *Result) { a.NoError(r.Err) })
+	p.(*pagePoller).poll(context.Background())
+
+	// -- Only the step preserves the order
+	a.Equal([]string{"Host", "Zzz", "Accept"}, (<-heads)[:3])
+	head := <-heads
+	a.Less(indexOf(head, "Accept"), indexOf(head, "Zzz"))
+}
+
+func indexOf(list []string, val string) int {
+	for i, v := range list {
+		if v == val {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestReorderHead(t *testing.T) {
+	a := assert.New(t)
+
+	head := []byte("GET / HTTP/1.1\r\nHost: a\r\nAccept: b\r\nConnection: close\r\nUser-Agent: c")
+	a.Equal(
+		"GET / HTTP/1.1\r\nUser-Agent: c\r\nHost: a\r\nAccept: b\r\nConnection: close",
+		string(reorderHead(head, map[string]int{"user-agent": 0, "host": 1})),
+	)
+	a.Equal(
+		"GET / HTTP/1.1\r\nHost: a\r\nAccept: b\r\nConnection: close\r\nUser-Agent: c",
+		string(reorderHead(head, map[string]int{})),
+	)
+}
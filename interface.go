@@ -1,6 +1,10 @@
 package websitepoller
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Poller is in charge of polling a website and providing results to a
 // function that will handle the result
@@ -10,6 +14,50 @@ type Poller interface {
 	// SetHandlerFunc sets the function that will be called when a poll has
 	// finished
 	SetHandlerFunc(HandlerFunc)
+	// Use adds middlewares that results pass through before reaching the
+	// handler func. Middlewares run in the order they are added, the first
+	// one being the outermost.
+	Use(...Middleware)
+	// Results returns a channel where the results of the polls are sent,
+	// as an alternative to the handler func. The channel is closed when the
+	// poller stops.
+	Results() <-chan Result
+	// SetResultsOptions sets the size of the buffer of the results channel
+	// and what happens when it is full. It must be called before Results.
+	SetResultsOptions(ResultsOptions)
+	// SetErrorFunc sets the function that will be called with errors that
+	// cannot be passed to the handler func, i.e. a *PanicError when the
+	// handler panics
+	SetErrorFunc(ErrorFunc)
+	// SetHeaderFunc sets the function that is called with the headers of
+	// each request to the page, right before it is sent, so that they can
+	// be changed on each poll
+	SetHeaderFunc(HeaderFunc)
+	// SetAuthenticator sets the authenticator of the requests to the page,
+	// overriding the one defined in the page, if any
+	SetAuthenticator(Authenticator)
+	// SetStore sets the store where polls are recorded. The state of the
+	// poller is restored from the store when polling starts.
+	SetStore(Store)
+	// Stats returns the counters of the polls performed so far
+	Stats() Stats
+	// Status returns the current state of the poller, including its stats
+	// and the summaries of the most recent polls
+	Status() Status
+	// Poll polls the page right away, regardless of the schedule, and
+	// returns when the handler func has returned
+	Poll(ctx context.Context)
+	// Pause stops scheduled polls until Resume is called
+	Pause()
+	// Resume restarts scheduled polls after Pause
+	Resume()
+	// Page returns the page that the poller was created with. It must not
+	// be modified.
+	Page() Page
+	// SetTracerProvider sets the provider of the tracer used to create an
+	// OpenTelemetry span for each poll. By default, the global provider is
+	// used, which records nothing unless the program sets one.
+	SetTracerProvider(trace.TracerProvider)
 	// GetID returns the ID of this poller. If the `Page` struct provided
 	// to `New` contained a non-empty `ID`, then this returns the same ID as
 	// the one contained in there, otherwise it returns a randomly generated
@@ -0,0 +1,160 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. Only a subset of JSONPath is
+// supported: the root ($), child keys (.key or ['key']), array indexes
+// ([0], [-1]) and wildcards (.* or [*]).
+type jsonPath struct {
+	steps    []jsonPathStep
+	wildcard bool
+}
+
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseJSONPath(expr string) (*jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("%w: must start with $", ErrInvalidJSONPath)
+	}
+
+	jp := &jsonPath{}
+	rest := expr[1:]
+	for len(rest) > 0 {
+		var step jsonPathStep
+
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%w: empty key in %s", ErrInvalidJSONPath, expr)
+			}
+
+			if rest[:end] == "*" {
+				step.wildcard = true
+			} else {
+				step.key = rest[:end]
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unclosed bracket in %s", ErrInvalidJSONPath, expr)
+			}
+
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "*":
+				step.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				step.key = inner[1 : len(inner)-1]
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid index %q in %s", ErrInvalidJSONPath, inner, expr)
+				}
+				step.index, step.isIndex = index, true
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q in %s", ErrInvalidJSONPath, rest[0], expr)
+		}
+
+		jp.wildcard = jp.wildcard || step.wildcard
+		jp.steps = append(jp.steps, step)
+	}
+
+	return jp, nil
+}
+
+// evaluate returns the value pointed by the expression as a string. Strings
+// are returned as they are, any other value is encoded as JSON. If the
+// expression contains a wildcard, all matches are returned as a JSON array.
+func (jp *jsonPath) evaluate(data interface{}) (string, bool) {
+	nodes := []interface{}{data}
+
+	for _, step := range jp.steps {
+		next := []interface{}{}
+		for _, node := range nodes {
+			next = append(next, step.apply(node)...)
+		}
+		nodes = next
+	}
+
+	if jp.wildcard {
+		return jsonValue(nodes)
+	}
+
+	if len(nodes) == 0 {
+		return "", false
+	}
+
+	return jsonValue(nodes[0])
+}
+
+func (s jsonPathStep) apply(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			// -- Sort the keys so that results are stable across polls
+			keys := make([]string, 0, len(n))
+			for k := range n {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			vals := make([]interface{}, 0, len(n))
+			for _, k := range keys {
+				vals = append(vals, n[k])
+			}
+			return vals
+		}
+		if v, exists := n[s.key]; exists && !s.isIndex {
+			return []interface{}{v}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return n
+		}
+		if !s.isIndex {
+			return nil
+		}
+
+		index := s.index
+		if index < 0 {
+			index += len(n)
+		}
+		if index >= 0 && index < len(n) {
+			return []interface{}{n[index]}
+		}
+	}
+
+	return nil
+}
+
+func jsonValue(v interface{}) (string, bool) {
+	if str, ok := v.(string); ok {
+		return str, true
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+
+	return string(encoded), true
+}
@@ -0,0 +1,218 @@
+package websitepoller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Manager runs several pollers together and applies the same middlewares
+// to all of them.
+type Manager struct {
+	pollers     []Poller
+	byID        map[string]Poller
+	middlewares []Middleware
+	cancels     map[string]context.CancelFunc
+	ctx         context.Context
+	now         bool
+	running     sync.WaitGroup
+	resultsOpts ResultsOptions
+	results     *resultsChan
+	forwarding  sync.WaitGroup
+	lock        sync.Mutex
+}
+
+// NewManager returns a new manager with no pollers
+func NewManager() *Manager {
+	return &Manager{
+		byID:    map[string]Poller{},
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// Add adds the given pollers to the manager, with the middlewares of the
+// manager. Pollers added while the manager is running are started right
+// away. An error is returned if a poller has the same ID as one already
+// added, in which case none of the pollers are added.
+func (m *Manager) Add(pollers ...Poller) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	adding := map[string]bool{}
+	for _, p := range pollers {
+		id := p.GetID()
+		if _, exists := m.byID[id]; exists || adding[id] {
+			return fmt.Errorf("%w: %s", ErrDuplicatePoller, id)
+		}
+		adding[id] = true
+	}
+
+	for _, p := range pollers {
+		if len(m.middlewares) > 0 {
+			p.Use(m.middlewares...)
+		}
+
+		m.pollers = append(m.pollers, p)
+		m.byID[p.GetID()] = p
+
+		if m.ctx != nil {
+			m.start(p)
+		}
+	}
+
+	return nil
+}
+
+// Use adds middlewares to all the pollers of the manager, including the
+// ones added later. Middlewares added to a poller run in the order they are
+// added, regardless of whether they were added to the poller directly or
+// through the manager.
+func (m *Manager) Use(middlewares ...Middleware) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.middlewares = append(m.middlewares, middlewares...)
+	for _, p := range m.pollers {
+		p.Use(middlewares...)
+	}
+}
+
+// Remove stops the poller with the given ID, if running, and removes it from
+// the manager
+func (m *Manager) Remove(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, exists := m.byID[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrPollerNotFound, id)
+	}
+
+	if cancel, running := m.cancels[id]; running {
+		cancel()
+		delete(m.cancels, id)
+	}
+	delete(m.byID, id)
+	for i, p := range m.pollers {
+		if p.GetID() == id {
+			m.pollers = append(m.pollers[:i], m.pollers[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// Get returns the poller with the given ID, or nil if the manager has no
+// such poller
+func (m *Manager) Get(id string) Poller {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.byID[id]
+}
+
+// Pollers returns the pollers of the manager, in the order they were added
+func (m *Manager) Pollers() []Poller {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]Poller{}, m.pollers...)
+}
+
+// Start starts all the pollers and blocks until ctx is canceled and all of
+// them have stopped. If now is true, each poller polls as soon as it is
+// started.
+func (m *Manager) Start(ctx context.Context, now bool) {
+	m.lock.Lock()
+	m.ctx, m.now = ctx, now
+	for _, p := range m.pollers {
+		m.start(p)
+	}
+	m.lock.Unlock()
+
+	<-ctx.Done()
+	m.lock.Lock()
+	m.ctx = nil
+	m.cancels = map[string]context.CancelFunc{}
+	m.lock.Unlock()
+
+	m.running.Wait()
+
+	// -- Channels of the pollers are closed now: wait for the remaining
+	// results to be forwarded
+	m.forwarding.Wait()
+	m.lock.Lock()
+	results := m.results
+	m.results = nil
+	m.lock.Unlock()
+	if results != nil {
+		results.close()
+	}
+}
+
+// start starts the given poller in its own goroutine. It must be called with
+// the lock held.
+func (m *Manager) start(p Poller) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	now := m.now
+	m.cancels[p.GetID()] = cancel
+	if m.results != nil {
+		m.forward(p)
+	}
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		defer cancel()
+		p.Start(ctx, now)
+	}()
+}
+
+// Results returns a channel where the results of all the pollers are sent,
+// including the ones added later, as with the Results method of pollers.
+// This takes the results channels of the pollers, so do not read them
+// directly. The channel is closed when Start returns, after which a new one
+// is returned. With the Block policy, keep reading until then, or Start
+// will not return.
+func (m *Manager) Results() <-chan Result {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.results == nil {
+		m.results = newResultsChan(m.resultsOpts)
+		if m.ctx != nil {
+			for _, p := range m.pollers {
+				m.forward(p)
+			}
+		}
+	}
+
+	return m.results.ch
+}
+
+// SetResultsOptions sets the options of the results channel of the manager.
+// They are used for the channels created from now on. The pollers keep
+// their own options, which apply to the results before they are forwarded.
+func (m *Manager) SetResultsOptions(opts ResultsOptions) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.resultsOpts = opts
+}
+
+// forward sends the results of the poller to the results channel of the
+// manager, until the poller stops. It must be called with the lock held,
+// before the poller is started.
+func (m *Manager) forward(p Poller) {
+	results, from := m.results, p.Results()
+
+	m.forwarding.Add(1)
+	go func() {
+		defer m.forwarding.Done()
+		for res := range from {
+			if !results.send(context.Background(), res) {
+				log.Warn().Str("id", res.ID).Msg("results channel of manager is full, dropping result")
+			}
+		}
+	}()
+}
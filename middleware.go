@@ -0,0 +1,284 @@
+package websitepoller
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a HandlerFunc, i.e. to filter or transform results before
+// they reach it or to do something after it returns. Middlewares that keep
+// state, i.e. to deduplicate results, must keep it outside the returned
+// HandlerFunc, so that it is shared by all the handlers they wrap.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Chain returns a HandlerFunc that passes results through the given
+// middlewares, in order, and then to h: the first middleware is the
+// outermost one.
+func Chain(h HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+// Recover returns a middleware that recovers from panics occurred in the
+// handlers after it, so that they do not crash the program.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error().Str("id", res.ID).Interface("panic", r).Bytes("stack", debug.Stack()).Msg("recovered from panic in handler")
+				}
+			}()
+
+			next(res)
+		}
+	}
+}
+
+// Timing returns a middleware that measures how long the handlers after it
+// take and passes it to report, or logs it if report is nil.
+func Timing(report func(id string, elapsed time.Duration)) Middleware {
+	if report == nil {
+		report = func(id string, elapsed time.Duration) {
+			log.Info().Str("id", id).Dur("elapsed", elapsed).Msg("result handled")
+		}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			start := time.Now()
+			next(res)
+			report(res.ID, time.Since(start))
+		}
+	}
+}
+
+// LimitBody returns a middleware that prevents the handlers after it from
+// reading more than max bytes of the body: reading the response body fails
+// with ErrBodyTooLarge after max bytes and Result.Body is truncated.
+func LimitBody(max int64) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			if int64(len(res.Body)) > max {
+				res.Body = res.Body[:max]
+			}
+			if res.Response != nil && res.Response.Body != nil {
+				res.Response.Body = &limitedBody{body: res.Response.Body, left: max}
+			}
+
+			next(res)
+		}
+	}
+}
+
+type limitedBody struct {
+	body io.ReadCloser
+	left int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// -- Only fail if there is actually something left
+		var b [1]byte
+		if n, _ := l.body.Read(b[:]); n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.body.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	return l.body.Close()
+}
+
+// Decompress returns a middleware that decodes gzip, deflate, brotli and
+// zstd response bodies for the handlers after it. Pollers already do this,
+// so this is only needed for results that do not come from a poller.
+func Decompress() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			if res.Response == nil || res.Response.Body == nil {
+				next(res)
+				return
+			}
+
+			encodings := contentEncodings(res.Response)
+			if len(encodings) == 0 {
+				next(res)
+				return
+			}
+
+			body := res.Body
+			if body == nil {
+				var err error
+				body, err = ioutil.ReadAll(res.Response.Body)
+				res.Response.Body.Close()
+				res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+				if err != nil {
+					res.Err = err
+					next(res)
+					return
+				}
+			}
+
+			decoded, err := decodeBytes(body, encodings)
+			if err != nil {
+				log.Error().Str("id", res.ID).Err(err).Strs("encodings", encodings).Msg("could not decompress body")
+				res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+				next(res)
+				return
+			}
+
+			res.ContentEncoding = strings.Join(res.Response.Header.Values(contentEncodingHeaderKey), ", ")
+			res.Body = decoded
+			res.Response.Body = ioutil.NopCloser(bytes.NewReader(decoded))
+			res.Response.Header.Del(contentEncodingHeaderKey)
+			res.Response.Header.Del("Content-Length")
+			res.Response.ContentLength = int64(len(decoded))
+			res.Response.Uncompressed = true
+
+			next(res)
+		}
+	}
+}
+
+func decodeBytes(body []byte, encodings []string) ([]byte, error) {
+	r, err := newDecoder(ioutil.NopCloser(bytes.NewReader(body)), encodings)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// FilterStatus returns a middleware that only passes to the handlers after
+// it the results whose status code is accepted by keep. The status code of
+// failed requests is 0.
+func FilterStatus(keep func(statusCode int) bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			status := 0
+			if res.Response != nil {
+				status = res.Response.StatusCode
+			}
+
+			if keep(status) {
+				next(res)
+				return
+			}
+
+			closeBody(res)
+		}
+	}
+}
+
+// Dedup returns a middleware that only passes to the handlers after it the
+// results whose content is different from the previous one of the same
+// poller. The content is the one used for change detection if enabled,
+// otherwise the whole body. Failed requests are always passed.
+func Dedup() Middleware {
+	var (
+		lock sync.Mutex
+		last = map[string]string{}
+	)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *Result) {
+			if res.Err != nil || res.Response == nil {
+				next(res)
+				return
+			}
+
+			hash := res.Hash
+			if len(hash) == 0 {
+				body := res.Body
+				if body == nil {
+					var err error
+					body, err = ioutil.ReadAll(res.Response.Body)
+					res.Response.Body.Close()
+					res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+					if err != nil {
+						next(res)
+						return
+					}
+				}
+				hash = contentHash(body, nil, nil)
+			}
+
+			lock.Lock()
+			duplicate := last[res.ID] == hash
+			last[res.ID] = hash
+			lock.Unlock()
+
+			if duplicate {
+				closeBody(res)
+				return
+			}
+			next(res)
+		}
+	}
+}
+
+// FanOut returns a HandlerFunc that passes each result to all the given
+// handlers, in order. Each handler gets its own copy of the result and can
+// read the response body independently from the others.
+func FanOut(handlers ...HandlerFunc) HandlerFunc {
+	return func(res *Result) {
+		var body []byte
+		if res.Response != nil && res.Response.Body != nil && len(handlers) > 1 {
+			var err error
+			body, err = ioutil.ReadAll(res.Response.Body)
+			res.Response.Body.Close()
+			if err != nil {
+				log.Error().Str("id", res.ID).Err(err).Msg("could not read body for fan-out")
+			}
+		}
+
+		for _, h := range handlers {
+			if len(handlers) == 1 {
+				h(res)
+				return
+			}
+
+			copied := *res
+			if res.Response != nil {
+				resp := *res.Response
+				resp.Header = res.Response.Header.Clone()
+				if res.Response.Body != nil {
+					resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+				}
+				copied.Response = &resp
+			}
+			h(&copied)
+		}
+	}
+}
+
+// closeBody drains and closes the body of a result that is not passed on,
+// so that the connection can be reused.
+func closeBody(res *Result) {
+	if res.Response == nil || res.Response.Body == nil {
+		return
+	}
+
+	io.Copy(ioutil.Discard, res.Response.Body)
+	res.Response.Body.Close()
+	res.Response.Body = http.NoBody
+}
@@ -0,0 +1,188 @@
+package websitepoller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResult(id string, status int, body string) *Result {
+	return &Result{
+		ID: id,
+		Response: &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func readResultBody(t *testing.T, res *Result) string {
+	body, err := ioutil.ReadAll(res.Response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestChain(t *testing.T) {
+	a := assert.New(t)
+
+	calls := []string{}
+	mark := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(res *Result) {
+				calls = append(calls, name)
+				next(res)
+			}
+		}
+	}
+
+	h := Chain(func(*Result) { calls = append(calls, "handler") }, mark("one"), mark("two"))
+	h(&Result{})
+	a.Equal([]string{"one", "two", "handler"}, calls)
+}
+
+func TestMiddlewares(t *testing.T) {
+	a := assert.New(t)
+
+	// -- Recover
+	a.NotPanics(func() {
+		Chain(func(*Result) { panic("boom") }, Recover())(&Result{ID: "one"})
+	})
+
+	// -- Timing
+	var elapsed time.Duration
+	Chain(func(*Result) { time.Sleep(10 * time.Millisecond) }, Timing(func(id string, d time.Duration) {
+		a.Equal("one", id)
+		elapsed = d
+	}))(&Result{ID: "one"})
+	a.True(elapsed >= 10*time.Millisecond)
+
+	// -- LimitBody
+	Chain(func(res *Result) {
+		body, err := ioutil.ReadAll(res.Response.Body)
+		a.True(errors.Is(err, ErrBodyTooLarge))
+		a.Equal("0123", string(body))
+	}, LimitBody(4))(newTestResult("one", 200, "0123456789"))
+	Chain(func(res *Result) {
+		a.Equal("0123", readResultBody(t, res))
+	}, LimitBody(4))(newTestResult("one", 200, "0123"))
+
+	// -- FilterStatus
+	passed := 0
+	filtered := Chain(func(*Result) { passed++ }, FilterStatus(func(status int) bool { return status >= 400 }))
+	filtered(newTestResult("one", 200, ""))
+	filtered(newTestResult("one", 503, ""))
+	filtered(&Result{ID: "one", Err: errors.New("timeout")})
+	a.Equal(1, passed)
+
+	// -- Dedup
+	bodies := []string{}
+	dedup := Chain(func(res *Result) { bodies = append(bodies, readResultBody(t, res)) }, Dedup())
+	for _, body := range []string{"a", "a", "b", "a", "a"} {
+		dedup(newTestResult("one", 200, body))
+	}
+	dedup(newTestResult("two", 200, "a"))
+	a.Equal([]string{"a", "b", "a", "a"}, bodies)
+
+	// -- Decompress
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("hello"))
+	gz.Close()
+	res := newTestResult("one", 200, buf.String())
+	res.Response.Header.Set("Content-Encoding", "gzip")
+	Chain(func(res *Result) {
+		a.Equal("hello", readResultBody(t, res))
+		a.Empty(res.Response.Header.Get("Content-Encoding"))
+	}, Decompress())(res)
+}
+
+func TestFanOut(t *testing.T) {
+	a := assert.New(t)
+
+	bodies := []string{}
+	read := func(res *Result) { bodies = append(bodies, readResultBody(t, res)) }
+	FanOut(read, read, read)(newTestResult("one", 200, "body"))
+	a.Equal([]string{"body", "body", "body"}, bodies)
+}
+
+func TestManager(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	newPoller := func(id string) Poller {
+		p, err := New(&Page{ID: &id, URL: server.URL})
+		a.NoError(err)
+		return p
+	}
+
+	var (
+		lock    sync.Mutex
+		handled = map[string][]string{}
+		wg      sync.WaitGroup
+	)
+	mark := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(res *Result) {
+				lock.Lock()
+				handled[res.ID] = append(handled[res.ID], name)
+				lock.Unlock()
+				next(res)
+			}
+		}
+	}
+
+	m := NewManager()
+	m.Use(mark("global"))
+
+	one, two := newPoller("one"), newPoller("two")
+	one.Use(mark("own"))
+	for _, p := range []Poller{one, two} {
+		p.SetHandlerFunc(func(*Result) { wg.Done() })
+	}
+	a.NoError(m.Add(one, two))
+	a.True(errors.Is(m.Add(newPoller("one")), ErrDuplicatePoller))
+	a.Len(m.Pollers(), 2)
+	a.Equal(two, m.Get("two"))
+	a.Nil(m.Get("three"))
+
+	ctx, canc := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		m.Start(ctx, true)
+		close(done)
+	}()
+	wg.Wait()
+
+	// -- Pollers added while running are started right away
+	three := newPoller("three")
+	three.SetHandlerFunc(func(*Result) { wg.Done() })
+	wg.Add(1)
+	a.NoError(m.Add(three))
+	wg.Wait()
+
+	canc()
+	<-done
+
+	a.Equal([]string{"own", "global"}, handled["one"])
+	a.Equal([]string{"global"}, handled["two"])
+	a.Equal([]string{"global"}, handled["three"])
+}
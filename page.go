@@ -1,55 +1,381 @@
 package websitepoller
 
-import "net/http"
-
 // Page to poll
 type Page struct {
 	// ID is a short name that will be used by the logs to recognize when
 	// operations are performed on this page.
-	ID *string `yaml:"id,omitempty"`
-	// URL to poll
-	URL string `yaml:"url"`
+	ID *string `yaml:"id,omitempty" json:"id,omitempty"`
+	// URL to poll. URL, headers and body can contain variables with the
+	// ${name} syntax, which are evaluated on each poll. Besides the values
+	// captured by steps, the following built-ins are available:
+	// ${unix}, ${unixMilli}, ${date:<layout>}, ${utcDate:<layout>},
+	// ${randInt:<min>:<max>}, ${uuid}, ${counter}, ${env:<name>} and
+	// ${file:<path>}, where layout is a layout for time.Format.
+	URL string `yaml:"url" json:"url"`
 	// Method of the request, e.g.: GET
-	Method *string `yaml:"method,omitempty"`
-	// Headers to send with the request
-	Headers map[string]string `yaml:"headers,omitempty"`
+	Method *string `yaml:"method,omitempty" json:"method,omitempty"`
+	// Headers to send with the request, in order
+	Headers Headers `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// PreserveHeaderOrder specifies whether headers must be written on the
+	// wire in the order they are defined, rather than in the alphabetical
+	// order used by Go, as some websites fingerprint it. This forces
+	// HTTP/1.1 and disables keep-alive connections. Default is false
+	PreserveHeaderOrder bool `yaml:"preserveHeaderOrder,omitempty" json:"preserveHeaderOrder,omitempty"`
+	// Body to send with the request
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+	// AuthOptions contains options about authenticating the requests to
+	// the page. Leave this nil for no authentication.
+	*AuthOptions `yaml:"auth,omitempty" json:"auth,omitempty"`
 	// UserAgentOptions contains options about the
 	// user agent
-	*UserAgentOptions `yaml:"userAgentOptions,omitempty"`
+	*UserAgentOptions `yaml:"userAgentOptions,omitempty" json:"userAgentOptions,omitempty"`
 	// PollOptions contains options about polling
-	*PollOptions `yaml:"pollOptions,omitempty"`
-	// FollowRedirect specifies whether to follow redirects or not.
+	*PollOptions `yaml:"pollOptions,omitempty" json:"pollOptions,omitempty"`
+	// Steps is a list of requests performed, in order, before the request
+	// to URL, each time the page is polled. Values captured from the
+	// response of a step can be used in the URL, headers and body of the
+	// following steps and of the page with the ${name} syntax.
+	Steps []Step `yaml:"steps,omitempty" json:"steps,omitempty"`
+	// TLSOptions contains options about TLS connections to the page. Leave
+	// this nil to use the default settings.
+	*TLSOptions `yaml:"tls,omitempty" json:"tls,omitempty"`
+	// TransportOptions contains options about the connections to the page,
+	// i.e. the HTTP version and the connection pool. Leave this nil to use
+	// the default settings.
+	*TransportOptions `yaml:"transport,omitempty" json:"transport,omitempty"`
+	// FollowRedirect specifies whether to follow redirects or not. Up to
+	// 10 redirects are followed, unless a redirect policy says otherwise.
 	// Default is false
-	FollowRedirect bool `yaml:"followRedirect,omitempty"`
+	FollowRedirect bool `yaml:"followRedirect,omitempty" json:"followRedirect,omitempty"`
+	// RedirectPolicy contains options about following redirects, when
+	// FollowRedirect is true. Leave this nil to follow any redirect.
+	*RedirectPolicy `yaml:"redirectPolicy,omitempty" json:"redirectPolicy,omitempty"`
+	// MaxBodySize is the maximum number of bytes read from the body of each
+	// response. Larger bodies are truncated and the result is marked as
+	// such. Default is 10 MiB
+	MaxBodySize int64 `yaml:"maxBodySize,omitempty" json:"maxBodySize,omitempty"`
+	// ConvertToUTF8 specifies whether text bodies should be transcoded to
+	// UTF-8 before they are passed to extractors, change detection and the
+	// handler. The charset is detected from the byte order mark, the
+	// Content-Type header or an HTML meta tag. Default is false
+	ConvertToUTF8 bool `yaml:"convertToUTF8,omitempty" json:"convertToUTF8,omitempty"`
+	// Trace specifies whether the duration of each phase of the request,
+	// i.e. DNS lookup or TLS handshake, should be measured and included in
+	// the result. Default is false
+	Trace bool `yaml:"trace,omitempty" json:"trace,omitempty"`
+	// Extractors is a list of named fields to extract from the body of
+	// each response. Extracted values are attached to the result passed to
+	// the handler.
+	Extractors []Extractor `yaml:"extractors,omitempty" json:"extractors,omitempty"`
+	// ChangeDetection contains options about detecting changes between
+	// successive polls. Leave this nil to disable change detection.
+	*ChangeDetectionOptions `yaml:"changeDetection,omitempty" json:"changeDetection,omitempty"`
+	// SnapshotOptions contains options about archiving response bodies.
+	// Leave this nil to disable snapshots.
+	*SnapshotOptions `yaml:"snapshots,omitempty" json:"snapshots,omitempty"`
 	// TODO: support cookies?
-	// TODO: support body
 }
 
 // UserAgentOptions contains options about the user agent
 type UserAgentOptions struct {
 	// UserAgents is a list of user agents that should be used for this page
-	UserAgents []string `yaml:"userAgents,omitempty"`
+	UserAgents []string `yaml:"userAgents,omitempty" json:"userAgents,omitempty"`
 	// RandomUA specifies whether the user agent for the next request should
 	// be chosen randomly or should be rotated. This has no effect if
 	// UserAgents is empty or only has one element. Leave this false if you
 	// have few user agents.
-	RandomUA bool `yaml:"randomUA"`
+	RandomUA bool `yaml:"randomUA" json:"randomUA"`
 }
 
 // PollOptions contains options about polling
 type PollOptions struct {
-	// Frequency of polling in seconds
-	Frequency int `yaml:"frequency"`
+	// Frequency of polling, i.e. "1m30s", or a number of seconds. It
+	// cannot be lower than MinFrequency. Default is 30 seconds
+	Frequency Duration `yaml:"frequency" json:"frequency"`
 	// Randomize specifies whether the next poll should be at a random time or
 	// at a fixed time
-	RandomFrequency bool `yaml:"randomFrequency"`
+	RandomFrequency bool `yaml:"randomFrequency" json:"randomFrequency"`
 	// OffsetRange specifies the range for choosing the next random time.
-	// For example, if Frequency is 30 and OffsetRange is 10, then each next
-	// poll will be performed at a random time in the [20, 40] seconds range,
-	// i.e. 27 seconds.
-	OffsetRange *int `yaml:"offsetRange,omitempty"`
+	// For example, if Frequency is 30s and OffsetRange is 10s, then each
+	// next poll will be performed at a random time in the [20s, 40s] range,
+	// i.e. 27 seconds. Frequency minus OffsetRange cannot be lower than
+	// MinFrequency. Default is 10 seconds
+	OffsetRange *Duration `yaml:"offsetRange,omitempty" json:"offsetRange,omitempty"`
+	// MinFrequency is the lowest frequency allowed. It can be lowered, i.e.
+	// to "500ms", down to 100ms and only if the URLs of the page and of its
+	// steps are on localhost or on loopback, private or link-local IP
+	// addresses: this is an explicit opt-in to poll that often. It also
+	// lowers the minimum offset range, which is 5 seconds by default. If
+	// raised above the default frequency, it is used as default instead.
+	// Default is 5 seconds
+	MinFrequency Duration `yaml:"minFrequency,omitempty" json:"minFrequency,omitempty"`
+	// MaxPanics is the number of consecutive polls that can panic, i.e.
+	// in the handler, before the poller is disabled. Zero means the poller
+	// is never disabled.
+	MaxPanics int `yaml:"maxPanics,omitempty" json:"maxPanics,omitempty"`
+}
+
+// Extractor defines a named field to extract from the body of a response.
+// Exactly one of CSS, XPath, JSONPath or Regex must be provided.
+type Extractor struct {
+	// Name of the field, used as key in the result's fields
+	Name string `yaml:"name" json:"name"`
+	// CSS selector, e.g.: div.price > span
+	CSS string `yaml:"css,omitempty" json:"css,omitempty"`
+	// XPath expression, e.g.: //div[@class='price']/span
+	XPath string `yaml:"xpath,omitempty" json:"xpath,omitempty"`
+	// JSONPath expression, e.g.: $.items[0].price
+	JSONPath string `yaml:"jsonPath,omitempty" json:"jsonPath,omitempty"`
+	// Regex is a regular expression. If it contains a capturing group, the
+	// value of the first group is extracted, otherwise the whole match is.
+	Regex string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	// Attribute to extract from the element matched by CSS or XPath
+	// instead of its text content, e.g.: href
+	Attribute string `yaml:"attribute,omitempty" json:"attribute,omitempty"`
+}
+
+// Step is a request performed before the request to the page, i.e. to get
+// a token needed by the page
+type Step struct {
+	// Name of the step, used in logs and errors
+	Name string `yaml:"name" json:"name"`
+	// URL of the request
+	URL string `yaml:"url" json:"url"`
+	// Method of the request, e.g.: POST. Default is GET
+	Method *string `yaml:"method,omitempty" json:"method,omitempty"`
+	// Headers to send with the request, in order
+	Headers Headers `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// PreserveHeaderOrder specifies whether the headers of the step must be
+	// written on the wire in the order they are defined, like the ones of
+	// the page. This forces HTTP/1.1 and disables keep-alive connections for
+	// all the requests of the page. Default is false
+	PreserveHeaderOrder bool `yaml:"preserveHeaderOrder,omitempty" json:"preserveHeaderOrder,omitempty"`
+	// Body to send with the request
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+	// Captures is a list of values to capture from the response
+	Captures []Capture `yaml:"captures,omitempty" json:"captures,omitempty"`
+}
+
+// Capture defines a named value to capture from the response of a step.
+// Exactly one of Header, Cookie or the expressions of the Extractor must
+// be provided. The poll fails if the value cannot be found.
+type Capture struct {
+	// Extractor to capture the value from the body. Its name is used as
+	// name of the variable.
+	Extractor `yaml:",inline"`
+	// Header is the name of the header whose value is captured
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+	// Cookie is the name of the cookie whose value is captured
+	Cookie string `yaml:"cookie,omitempty" json:"cookie,omitempty"`
+}
+
+// AuthOptions contains options about authentication. Exactly one provider
+// must be defined. Authentication is only applied to the request to the
+// page, not to its steps.
+type AuthOptions struct {
+	// Basic authentication
+	Basic *BasicAuth `yaml:"basic,omitempty" json:"basic,omitempty"`
+	// Bearer authentication with a static token
+	Bearer *BearerAuth `yaml:"bearer,omitempty" json:"bearer,omitempty"`
+	// OAuth2 authentication with the client credentials flow
+	OAuth2 *OAuth2Auth `yaml:"oauth2,omitempty" json:"oauth2,omitempty"`
+	// AWSSigV4 signs requests with AWS Signature Version 4
+	AWSSigV4 *AWSSigV4Auth `yaml:"awsSigV4,omitempty" json:"awsSigV4,omitempty"`
+	// HMAC signs requests with a shared key
+	HMAC *HMACAuth `yaml:"hmac,omitempty" json:"hmac,omitempty"`
+}
+
+// Secret is a value that is loaded from an environment variable or a file,
+// so that it does not have to be written in the configuration. Exactly one
+// of Env or File must be provided. The value is loaded each time it is
+// needed, so that it can be rotated without restarting.
+type Secret struct {
+	// Env is the name of the environment variable containing the secret
+	Env string `yaml:"env,omitempty" json:"env,omitempty"`
+	// File is the path of the file containing the secret. Trailing
+	// newlines are removed.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
+// BasicAuth contains options about basic authentication
+type BasicAuth struct {
+	// Username to authenticate with
+	Username string `yaml:"username" json:"username"`
+	// Password to authenticate with
+	Password Secret `yaml:"password" json:"password"`
+}
+
+// BearerAuth contains options about bearer authentication
+type BearerAuth struct {
+	// Token to send in the Authorization header
+	Token Secret `yaml:"token" json:"token"`
+}
+
+// OAuth2Auth contains options about the OAuth2 client credentials flow.
+// The token is requested on the first poll and refreshed before it expires.
+type OAuth2Auth struct {
+	// TokenURL is the URL of the token endpoint
+	TokenURL string `yaml:"tokenURL" json:"tokenURL"`
+	// ClientID of the application
+	ClientID string `yaml:"clientID" json:"clientID"`
+	// ClientSecret of the application
+	ClientSecret Secret `yaml:"clientSecret" json:"clientSecret"`
+	// Scopes to request
+	Scopes []string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	// Params contains additional parameters sent to the token endpoint,
+	// e.g.: audience
+	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty"`
 }
 
-// HandlerFunc represents a function that will handle the response returned
-// by the polling.
-type HandlerFunc func(string, *http.Response, error)
+// AWSSigV4Auth contains options about signing requests with AWS Signature
+// Version 4
+type AWSSigV4Auth struct {
+	// AccessKeyID to sign requests with
+	AccessKeyID Secret `yaml:"accessKeyID" json:"accessKeyID"`
+	// SecretAccessKey to sign requests with
+	SecretAccessKey Secret `yaml:"secretAccessKey" json:"secretAccessKey"`
+	// SessionToken for temporary credentials. Leave this nil if you are
+	// not using temporary credentials.
+	SessionToken *Secret `yaml:"sessionToken,omitempty" json:"sessionToken,omitempty"`
+	// Region of the service, e.g.: eu-west-1
+	Region string `yaml:"region" json:"region"`
+	// Service to sign requests for, e.g.: execute-api
+	Service string `yaml:"service" json:"service"`
+}
+
+// HMACAuth contains options about signing requests with a shared key
+type HMACAuth struct {
+	// Key used to sign requests
+	Key Secret `yaml:"key" json:"key"`
+	// Algorithm of the HMAC: sha1, sha256 or sha512. Default is sha256
+	Algorithm string `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	// Encoding of the signature: hex or base64. Default is hex
+	Encoding string `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+	// Header where the signature is written. Default is X-Signature
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+	// Prefix of the signature in the header, e.g.: sha256=
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	// TimestampHeader is the header where the unix time of the request is
+	// written. Leave this empty to not send it.
+	TimestampHeader string `yaml:"timestampHeader,omitempty" json:"timestampHeader,omitempty"`
+	// StringToSign is the template of the signed content. The variables
+	// ${method}, ${url}, ${path}, ${query}, ${timestamp} and ${body} are
+	// available. Default is "${method}\n${url}\n${timestamp}\n${body}"
+	StringToSign string `yaml:"stringToSign,omitempty" json:"stringToSign,omitempty"`
+}
+
+// TLSOptions contains options about TLS connections
+type TLSOptions struct {
+	// CAFile is the path of a PEM bundle with the certificate authorities
+	// to trust, instead of the ones of the system
+	CAFile string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	// CertFile is the path of the PEM client certificate, for mutual TLS
+	CertFile string `yaml:"certFile,omitempty" json:"certFile,omitempty"`
+	// KeyFile is the path of the PEM key of the client certificate
+	KeyFile string `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	// ServerName overrides the name used to verify the certificate of the
+	// server and sent with SNI
+	ServerName string `yaml:"serverName,omitempty" json:"serverName,omitempty"`
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+	// Default is 1.2
+	MinVersion string `yaml:"minVersion,omitempty" json:"minVersion,omitempty"`
+	// PinnedKeys is a list of base64-encoded SHA-256 hashes of the
+	// public keys (SPKI) of the server: the connection fails unless a
+	// certificate in the verified chain has one of them, i.e.
+	// sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=
+	PinnedKeys []string `yaml:"pinnedKeys,omitempty" json:"pinnedKeys,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the server. Pinned keys are still checked, against the certificate of
+	// the server only. Only use this for testing. Default is false
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+}
+
+// TransportOptions contains options about the connections to a page
+type TransportOptions struct {
+	// HTTPVersion is the version of HTTP used: "1.1" or "2". HTTP/2 is
+	// used with prior knowledge on plain HTTP URLs. Leave this empty to
+	// negotiate it with the server. HTTP/3 is not supported.
+	HTTPVersion string `yaml:"httpVersion,omitempty" json:"httpVersion,omitempty"`
+	// DisableKeepAlives specifies whether each request is sent on a new
+	// connection, as some websites fingerprint reused connections.
+	// Default is false
+	DisableKeepAlives bool `yaml:"disableKeepAlives,omitempty" json:"disableKeepAlives,omitempty"`
+	// MaxIdleConns is the maximum number of idle connections across all
+	// hosts. Default is 100
+	MaxIdleConns int `yaml:"maxIdleConns,omitempty" json:"maxIdleConns,omitempty"`
+	// MaxIdleConnsPerHost is the maximum number of idle connections to each
+	// host. Default is 2
+	MaxIdleConnsPerHost int `yaml:"maxIdleConnsPerHost,omitempty" json:"maxIdleConnsPerHost,omitempty"`
+	// MaxConnsPerHost is the maximum number of connections to each host,
+	// including the ones in use. Zero means no limit.
+	MaxConnsPerHost int `yaml:"maxConnsPerHost,omitempty" json:"maxConnsPerHost,omitempty"`
+	// IdleConnTimeout is how long an idle connection is kept open, i.e.
+	// "30s". Default is 90 seconds
+	IdleConnTimeout Duration `yaml:"idleConnTimeout,omitempty" json:"idleConnTimeout,omitempty"`
+	// DialTimeout is how long to wait for a connection to be established.
+	// Default is 30 seconds
+	DialTimeout Duration `yaml:"dialTimeout,omitempty" json:"dialTimeout,omitempty"`
+	// ResponseHeaderTimeout is how long to wait for the headers of the
+	// response once the request is written. Zero means no limit other than
+	// the timeout of the whole request.
+	ResponseHeaderTimeout Duration `yaml:"responseHeaderTimeout,omitempty" json:"responseHeaderTimeout,omitempty"`
+	// Shared specifies whether the connections can be shared with the other
+	// pollers whose transport and TLS options are the same. Otherwise, the
+	// poller has its own connection pool. Default is false
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty"`
+}
+
+// ChangeDetectionOptions contains options about change detection
+type ChangeDetectionOptions struct {
+	// Fields is a list of extractors names whose values are used to detect
+	// changes. If empty and extractors are defined, all extracted values are
+	// used. If no extractors are defined, the whole body is used.
+	Fields []string `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// SnapshotOptions contains options about archiving response bodies as
+// snapshots on disk
+type SnapshotOptions struct {
+	// Dir is the directory where snapshots are archived. Snapshots are
+	// compressed and named after the hash of their content, so identical
+	// bodies are only archived once. The same directory can be shared
+	// among several pages.
+	Dir string `yaml:"dir" json:"dir"`
+	// Diff is the format of the diff between a snapshot and the previous
+	// distinct one: "text" for unified diffs, "json" for structural JSON
+	// diffs. Leave this empty to disable diffs.
+	Diff string `yaml:"diff,omitempty" json:"diff,omitempty"`
+}
+
+// RedirectPolicy contains options about following redirects
+type RedirectPolicy struct {
+	// MaxHops is the maximum number of redirects followed, after which the
+	// poll fails with ErrTooManyRedirects. Default is 10
+	MaxHops int `yaml:"maxHops,omitempty" json:"maxHops,omitempty"`
+	// SameHost specifies whether only redirects to the host of the page
+	// are followed. Default is false
+	SameHost bool `yaml:"sameHost,omitempty" json:"sameHost,omitempty"`
+	// AllowedDomains is a list of domains, including their subdomains,
+	// that redirects can lead to. If SameHost is true, the host of the
+	// page is allowed too. Redirects that are not allowed are not followed
+	// and their response is the result of the poll. Leave this empty to
+	// allow any domain.
+	AllowedDomains []string `yaml:"allowedDomains,omitempty" json:"allowedDomains,omitempty"`
+	// Method defines how the method and body of the request change when
+	// redirected. By default, they are kept after 307 and 308, while 301,
+	// 302 and 303 are followed with a GET without body, as browsers do.
+	// With "keep", they are kept after 301 and 302 too. With "get", all
+	// redirects are followed with a GET without body.
+	Method string `yaml:"method,omitempty" json:"method,omitempty"`
+	// StripHeaders is a list of headers removed from the request when it
+	// is redirected to a different host, i.e. X-Api-Key. Authorization,
+	// Proxy-Authorization and Cookie are always removed.
+	StripHeaders []string `yaml:"stripHeaders,omitempty" json:"stripHeaders,omitempty"`
+}
+
+// HandlerFunc represents a function that will handle the result of a poll.
+type HandlerFunc func(*Result)
+
+// ErrorFunc represents a function that will handle errors that cannot be
+// passed to the HandlerFunc, i.e. panics occurred in the HandlerFunc itself.
+type ErrorFunc func(id string, err error)
@@ -1,13 +1,22 @@
 package websitepoller
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
 	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	randomdata "github.com/Pallinder/go-randomdata"
 	"github.com/rs/zerolog"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -15,13 +24,15 @@ var (
 )
 
 const (
-	defaultFrequency         int    = 30
-	minFrequency             int    = 5
-	minOffset                int    = 5
-	minRandomFrequency       int    = 15
-	defaultOffsetRange       int    = 10
-	defaultHTTPClientTimeout int    = 20
-	userAgentHeaderKey       string = "User-Agent"
+	defaultFrequency         time.Duration = 30 * time.Second
+	minFrequency             time.Duration = 5 * time.Second
+	minFrequencyFloor        time.Duration = 100 * time.Millisecond
+	minOffset                time.Duration = 5 * time.Second
+	minRandomFrequency       time.Duration = 15 * time.Second
+	defaultOffsetRange       time.Duration = 10 * time.Second
+	defaultHTTPClientTimeout int           = 20
+	userAgentHeaderKey       string        = "User-Agent"
+	maxRecentResults         int           = 10
 )
 
 func init() {
@@ -33,18 +44,58 @@ func init() {
 type pagePoller struct {
 	id          string
 	httpClient  *http.Client
-	request     *http.Request
+	request     *requestTemplate
+	headerOrder map[string]int
+	steps       []*step
 	userAgents  []string
-	ticks       int
+	frequency   time.Duration
 	randTick    bool
-	offsetRange int
+	offsetRange time.Duration
 	lastUAIndex int
+	counter     uint64
 	randUa      bool
+	extractors  []*extractor
+	detect      bool
+	detectOn    []string
+	lastHash    string
+	lock        sync.Mutex
+	store       Store
+	snapshots   *snapshotArchive
+	headerFunc  HeaderFunc
+	auth        Authenticator
+	middlewares []Middleware
+	handler     HandlerFunc
+	errorFunc   ErrorFunc
+	maxPanics   int
+	maxBodySize int64
+	toUTF8      bool
+	trace       bool
+	tracer      oteltrace.Tracer
+	page        Page
+	paused      int32
+	nextPoll    int64
+	recent      []*Summary
+	results     *resultsChan
+	resultsOpts ResultsOptions
+	stats       pollerStats
 	HandlerFunc
 }
 
-// New returns a new instance of the poller
+// New returns a new instance of the poller. If the page is not valid, it
+// returns a *ValidationError with all its problems.
 func New(p *Page) (Poller, error) {
+	poller, err := newPagePoller(p, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return poller, nil
+}
+
+// newPagePoller validates the page and returns a poller for it. If strict
+// is true, poll options that would be replaced by the defaults are
+// problems too.
+func newPagePoller(p *Page, strict bool) (*pagePoller, error) {
 	id := ""
 	l := log.With().Str("func", "poller.New").Logger()
 
@@ -57,30 +108,67 @@ func New(p *Page) (Poller, error) {
 	l = l.With().Str("id", id).Logger()
 
 	// -- Validation
+	v := &validator{}
 	method, err := parseHTTPMethod(p.Method)
-	if err != nil {
-		return nil, err
+	v.check("method", err)
+	validURL := v.check("url", parseTemplatedURL(p.URL))
+	steps, stepVars, err := parseSteps(p.Steps)
+	validSteps := v.check("steps", err)
+	if strict {
+		checkPollOptions(v, p.PollOptions, requestHosts(p))
 	}
-	parsedURL, err := parseURL(p.URL)
-	if err != nil {
+
+	extractors, err := parseExtractors(p.Extractors)
+	var (
+		detect   bool
+		detectOn []string
+	)
+	if v.check("extractors", err) {
+		detect, detectOn, err = parseChangeDetectionOptions(p.ChangeDetectionOptions, extractors)
+		v.check("changeDetection", err)
+	}
+	snapshots, err := parseSnapshotOptions(id, p.SnapshotOptions)
+	v.check("snapshots", err)
+	tlsConfig, err := parseTLSOptions(id, p.TLSOptions)
+	v.check("tls", err)
+	transport, err := newTransport(id, p, tlsConfig)
+	v.check("transport", err)
+	auth, err := parseAuthOptions(p.AuthOptions, transport)
+	v.check("auth", err)
+	checkRedirect, err := parseRedirectPolicy(id, p.FollowRedirect, p.RedirectPolicy)
+	v.check("redirectPolicy", err)
+
+	// -- Variables can only be checked once all steps are known
+	if validSteps {
+		if validURL {
+			v.check("url", checkTemplateVars(newRequestTemplate(method, p.URL, nil, ""), stepVars))
+		}
+		v.check("headers", checkTemplateVars(newRequestTemplate(method, "", p.Headers, ""), stepVars))
+		v.check("body", checkTemplateVars(newRequestTemplate(method, "", nil, p.Body), stepVars))
+	}
+
+	if err := v.err(); err != nil {
 		return nil, err
 	}
 
 	// -- Set ups
-	randomFrequency, ticks, offset := parsePollOptions(id, p.PollOptions)
-	if randomFrequency {
-		ticks = nextRandomTick(ticks-offset, ticks+offset)
-	}
+	randomFrequency, frequency, offset := parsePollOptions(id, p.PollOptions, requestHosts(p))
 
 	randUA, userAgents := parseUserAgentOptions(id, p.UserAgentOptions)
 
-	headers := http.Header{}
+	maxBodySize := defaultMaxBodySize
+	if p.MaxBodySize > 0 {
+		maxBodySize = p.MaxBodySize
+	}
+
+	maxPanics := 0
+	if p.PollOptions != nil && p.PollOptions.MaxPanics > 0 {
+		maxPanics = p.PollOptions.MaxPanics
+	}
+
 	if p.Headers == nil {
 		l.Warn().Msg("no headers provided")
 	} else {
-		for headerKey, headerVal := range p.Headers {
-			headers[headerKey] = []string{headerVal}
-		}
 		switch hlen := len(p.Headers); {
 		case hlen == 0:
 			l.Warn().Msg("no headers provided")
@@ -90,40 +178,58 @@ func New(p *Page) (Poller, error) {
 	}
 
 	httpClient := &http.Client{
-		Timeout: time.Duration(defaultHTTPClientTimeout) * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+		Timeout:       time.Duration(defaultHTTPClientTimeout) * time.Second,
+		CheckRedirect: checkRedirect,
 	}
-	if p.FollowRedirect {
-		httpClient.CheckRedirect = nil
+	if transport != nil {
+		httpClient.Transport = transport
 	}
 
-	request, err := http.NewRequestWithContext(context.Background(), method, parsedURL.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	request.Header = headers
+	request := newRequestTemplate(method, p.URL, p.Headers, p.Body)
 
 	// -- Complete and return
+	page := *p
+	page.ID = &id
+
 	return &pagePoller{
 		id:          id,
 		httpClient:  httpClient,
 		request:     request,
+		headerOrder: headerOrder(p.PreserveHeaderOrder, p.Headers),
+		steps:       steps,
 		userAgents:  userAgents,
-		ticks:       ticks,
+		frequency:   frequency,
 		randTick:    randomFrequency,
 		offsetRange: offset,
 		lastUAIndex: -1,
 		randUa:      randUA,
+		extractors:  extractors,
+		detect:      detect,
+		detectOn:    detectOn,
+		snapshots:   snapshots,
+		auth:        auth,
+		maxPanics:   maxPanics,
+		maxBodySize: maxBodySize,
+		toUTF8:      p.ConvertToUTF8,
+		trace:       p.Trace,
+		tracer:      defaultTracer(),
+		page:        page,
 	}, nil
 }
 
 // Start polling
 func (p *pagePoller) Start(ctx context.Context, now bool) {
+	defer p.closeResults()
+	p.holdTransport()
+	defer p.closeTransport()
+	p.restore()
+
 	if now {
 		p.poll(ctx)
 	}
+	if p.stats.isDisabled() {
+		return
+	}
 
 	if !p.randTick {
 		p.startFixed(ctx)
@@ -133,13 +239,20 @@ func (p *pagePoller) Start(ctx context.Context, now bool) {
 }
 
 func (p *pagePoller) startFixed(ctx context.Context) {
-	ticker := time.NewTicker(time.Duration(p.ticks) * time.Second)
+	freq := p.frequency
+	ticker := time.NewTicker(freq)
 	defer ticker.Stop()
+	defer p.setNextPoll(time.Time{})
 
+	p.setNextPoll(time.Now().Add(freq))
 	for {
 		select {
 		case <-ticker.C:
-			go p.poll(ctx)
+			if p.stats.isDisabled() {
+				return
+			}
+			p.setNextPoll(time.Now().Add(freq))
+			p.scheduledPoll(ctx)
 		case <-ctx.Done():
 			return
 		}
@@ -147,46 +260,475 @@ func (p *pagePoller) startFixed(ctx context.Context) {
 }
 
 func (p *pagePoller) startRandom(ctx context.Context) {
-	ticker := time.NewTimer(time.Duration(p.ticks) * time.Second)
+	first := nextRandomTick(p.frequency-p.offsetRange, p.frequency+p.offsetRange)
+	ticker := time.NewTimer(first)
 	defer ticker.Stop()
+	defer p.setNextPoll(time.Time{})
 
+	p.setNextPoll(time.Now().Add(first))
 	for {
 		select {
 		case <-ticker.C:
-			go p.poll(ctx)
-			next := nextRandomTick(p.ticks-p.offsetRange, p.ticks+p.offsetRange)
-			ticker.Reset(time.Duration(next) * time.Second)
+			if p.stats.isDisabled() {
+				return
+			}
+			p.scheduledPoll(ctx)
+			next := nextRandomTick(p.frequency-p.offsetRange, p.frequency+p.offsetRange)
+			ticker.Reset(next)
+			p.setNextPoll(time.Now().Add(next))
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// scheduledPoll polls the page in the background, unless the poller is
+// paused or a previous result is still waiting for the consumer of the
+// results channel
+func (p *pagePoller) scheduledPoll(ctx context.Context) {
+	if p.isPaused() {
+		return
+	}
+	if p.resultsBlocked() {
+		log.Debug().Str("id", p.id).Msg("results channel is full, skipping poll")
+		return
+	}
+
+	go p.poll(ctx)
+}
+
+func (p *pagePoller) setNextPoll(t time.Time) {
+	next := int64(0)
+	if !t.IsZero() {
+		next = t.UnixNano()
+	}
+	atomic.StoreInt64(&p.nextPoll, next)
+}
+
+func (p *pagePoller) isPaused() bool {
+	return atomic.LoadInt32(&p.paused) == 1
+}
+
+// Poll polls the page right away, regardless of the schedule, and returns
+// when the handler func has returned
+func (p *pagePoller) Poll(ctx context.Context) {
+	p.poll(ctx)
+}
+
+// Pause stops scheduled polls until Resume is called. Polls can still be
+// performed with Poll.
+func (p *pagePoller) Pause() {
+	atomic.StoreInt32(&p.paused, 1)
+}
+
+// Resume restarts scheduled polls after Pause
+func (p *pagePoller) Resume() {
+	atomic.StoreInt32(&p.paused, 0)
+}
+
+// Page returns the page that the poller was created with, with its ID
+func (p *pagePoller) Page() Page {
+	return p.page
+}
+
+// Status returns the current state of the poller
+func (p *pagePoller) Status() Status {
+	s := Status{
+		ID:     p.id,
+		Paused: p.isPaused(),
+		Stats:  p.stats.snapshot(),
+	}
+
+	if next := atomic.LoadInt64(&p.nextPoll); next > 0 {
+		t := time.Unix(0, next)
+		s.NextPoll = &t
+	}
+
+	p.lock.Lock()
+	s.Recent = append([]*Summary{}, p.recent...)
+	p.lock.Unlock()
+
+	return s
+}
+
+// addRecent keeps the summary of the result among the recent ones
+func (p *pagePoller) addRecent(res *Result) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.recent = append([]*Summary{Summarize(res, false)}, p.recent...)
+	if len(p.recent) > maxRecentResults {
+		p.recent = p.recent[:maxRecentResults]
+	}
+}
+
 func (p *pagePoller) poll(ctx context.Context) {
+	defer p.recoverPanic()
+
+	// -- Polls outside of Start, i.e. through Poll, release the shared
+	// transport when they are done
+	p.holdTransport()
+	defer p.releaseTransport()
+
 	// -- Get the user agent for this request,
 	// and get the one for the next request
+	// -- Polls can be performed concurrently, i.e. through Poll
+	p.lock.Lock()
 	userAgent, index := getNextUA(p.id, p.userAgents, p.randUa, p.lastUAIndex)
 	p.lastUAIndex = index
+	store := p.store
+	p.lock.Unlock()
+
+	var trace *pollTrace
+	if p.trace {
+		trace = &pollTrace{}
+	}
+
+	ctx, span := p.startPollSpan(ctx, userAgent)
+	startedAt := time.Now()
+	redirects := &redirectChain{}
+	resp, err := p.do(ctx, userAgent, trace, redirects)
+	res := &Result{
+		ID:        p.id,
+		Time:      startedAt,
+		Duration:  time.Since(startedAt),
+		Response:  resp,
+		Err:       err,
+		Redirects: redirects.list(),
+	}
+	if err == nil {
+		p.readResponse(res)
+	}
+	// -- Timings end when the body is read, without the time taken to
+	// process it
+	readAt := time.Now()
+	if res.Err == nil {
+		p.processBody(res)
+	}
+	if trace != nil {
+		// -- Failed requests get the phases they reached, i.e. to tell a
+		// slow DNS from a server that never answers
+		res.Timings = trace.timings(readAt)
+		if res.Err == nil {
+			p.stats.recordTimings(res.Timings)
+		}
+	}
+	// -- Once the body is processed, so that bodies that could not be read
+	// and error statuses count as failures too
+	p.stats.record(Classify(res))
+	res.Err = pollError(p.id, res.Err)
+	endPollSpan(span, res)
+	p.addRecent(res)
+
+	if store != nil {
+		if err := store.Save(newRecord(res, index)); err != nil {
+			log.Error().Str("id", p.id).Err(err).Msg("could not save poll to store")
+		}
+	}
+
+	p.publishResult(ctx, res)
+
+	// -- Pass the result to the handler func
+	p.lock.Lock()
+	handler := p.handler
+	p.lock.Unlock()
+	if handler != nil {
+		handler(res)
+	}
+	p.stats.resetPanics()
+}
+
+// recoverPanic recovers from a panic occurred while polling, i.e. in the
+// handler, so that it does not crash the program, and disables the poller
+// if too many consecutive polls panicked.
+func (p *pagePoller) recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	l := log.With().Str("id", p.id).Logger()
+	err := &PanicError{ID: p.id, Value: r, Stack: debug.Stack()}
+	l.Error().Interface("panic", r).Bytes("stack", err.Stack).Msg("recovered from panic while polling")
+	p.reportError(err)
+
+	count := p.stats.recordPanic()
+	if p.maxPanics > 0 && count >= p.maxPanics && p.stats.disable() {
+		l.Error().Int("panics", count).Msg("too many consecutive panics, disabling poller...")
+		p.reportError(fmt.Errorf("%w after %d consecutive panics", ErrPollerDisabled, count))
+	}
+}
+
+// reportError passes err to the error func, if any
+func (p *pagePoller) reportError(err error) {
+	p.lock.Lock()
+	f := p.errorFunc
+	p.lock.Unlock()
+	if f == nil {
+		return
+	}
+
+	// -- The error func must not panic in turn
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("id", p.id).Interface("panic", r).Msg("recovered from panic in error func")
+		}
+	}()
+	f(p.id, err)
+}
+
+// do performs the steps, if any, and then the request to the page, traced
+// if trace is not nil. The redirects followed by the request to the page
+// are collected in redirects.
+func (p *pagePoller) do(ctx context.Context, userAgent string, trace *pollTrace, redirects *redirectChain) (*http.Response, error) {
+	client := p.httpClient
+	vars := map[string]string{}
+	lookup := pollLookup(vars, atomic.AddUint64(&p.counter, 1))
+
+	if len(p.steps) > 0 {
+		// -- Steps share cookies with each other and with the page, i.e.
+		// for sessions initiated by a login step
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		withJar := *p.httpClient
+		withJar.Jar = jar
+		client = &withJar
 
-	// -- Clone the request
-	req := p.request.Clone(ctx)
+		if err := p.runSteps(ctx, client, userAgent, vars, lookup); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := p.request.build(withHeaderOrder(withRedirectChain(ctx, redirects), p.headerOrder), lookup)
+	if err != nil {
+		return nil, err
+	}
+	req = traceRequest(ctx, req)
 	if len(userAgent) > 0 {
 		req.Header.Set(userAgentHeaderKey, userAgent)
 	}
+	p.lock.Lock()
+	headerFunc, auth := p.headerFunc, p.auth
+	p.lock.Unlock()
+	if headerFunc != nil {
+		headerFunc(p.id, req.Header)
+	}
+
+	// -- Last, as signatures may depend on everything else
+	if auth != nil {
+		if err := auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+
+	// -- After authenticating, so that token requests are not timed
+	if trace != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	}
+
+	return client.Do(req)
+}
+
+// readResponse decodes and reads the body of the response. The body of the
+// response is always closed, so that the connection can be reused, and
+// replaced so that it can still be read by the handler.
+func (p *pagePoller) readResponse(res *Result) {
+	encoding, err := decodeResponse(res.Response)
+	if err != nil {
+		res.Response.Body = http.NoBody
+		res.Err = err
+		return
+	}
+	res.ContentEncoding = encoding
 
-	resp, err := p.httpClient.Do(req)
+	body, truncated, err := readBody(res.Response.Body, p.maxBodySize)
+	res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		res.Err = err
+		return
+	}
+	res.Body, res.Truncated = body, truncated
+	if truncated {
+		log.Warn().Str("id", p.id).Int64("maxBodySize", p.maxBodySize).Msg("body is too large, truncating it")
+	}
+}
+
+// processBody converts the body of the response to UTF-8 if needed, runs
+// the extractors, archives the snapshot and detects changes.
+func (p *pagePoller) processBody(res *Result) {
+	body := res.Body
+	if p.toUTF8 {
+		converted, charset, err := toUTF8(res.Response, body)
+		if err != nil {
+			log.Error().Str("id", p.id).Str("charset", charset).Err(err).Msg("could not convert body to utf-8, using it as it is")
+		} else {
+			body = converted
+			res.Body, res.Charset = body, charset
+			res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+	}
+
+	if len(p.extractors) > 0 {
+		res.Fields = extractFields(p.id, p.extractors, body)
+	}
+
+	if p.snapshots != nil {
+		snap, err := p.snapshots.save(body)
+		if err != nil {
+			log.Error().Str("id", p.id).Err(err).Msg("could not archive snapshot")
+		}
+		res.Snapshot = snap
+	}
 
-	// -- Pass response and error to the response handler func
-	if p.HandlerFunc != nil {
-		p.HandlerFunc(p.id, resp, err)
+	if !p.detect {
 		return
 	}
+
+	res.Hash = contentHash(body, res.Fields, p.detectOn)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	res.Changed = len(p.lastHash) > 0 && p.lastHash != res.Hash
+	p.lastHash = res.Hash
 }
 
 // SetHandlerFunc sets the function that will be called when a poll has
 // finished
 func (p *pagePoller) SetHandlerFunc(f HandlerFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	p.HandlerFunc = f
+	p.chain()
+}
+
+// Use adds middlewares that results pass through before reaching the
+// handler func, in the order they are added
+func (p *pagePoller) Use(middlewares ...Middleware) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.middlewares = append(p.middlewares, middlewares...)
+	p.chain()
+}
+
+// chain builds the handler from the middlewares and the handler func. It
+// must be called with the lock held.
+func (p *pagePoller) chain() {
+	if p.HandlerFunc == nil {
+		p.handler = nil
+		return
+	}
+
+	p.handler = Chain(p.HandlerFunc, p.middlewares...)
+}
+
+// SetErrorFunc sets the function that will be called with errors that
+// cannot be passed to the handler func, i.e. panics occurred in it
+func (p *pagePoller) SetErrorFunc(f ErrorFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.errorFunc = f
+}
+
+// Stats returns the counters of the polls performed so far
+func (p *pagePoller) Stats() Stats {
+	return p.stats.snapshot()
+}
+
+// SetHeaderFunc sets the function that is called with the headers of each
+// request to the page, right before it is sent
+func (p *pagePoller) SetHeaderFunc(f HeaderFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.headerFunc = f
+}
+
+// SetAuthenticator sets the authenticator of the requests to the page,
+// overriding the one defined in the page, if any
+func (p *pagePoller) SetAuthenticator(a Authenticator) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.auth = a
+}
+
+// SetStore sets the store where polls are recorded. The state of the
+// poller is restored from the store when polling starts.
+func (p *pagePoller) SetStore(s Store) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.store = s
+}
+
+// holdTransport keeps the shared transport of the poller, if any, until
+// releaseTransport is called, so that its connections are reused
+func (p *pagePoller) holdTransport() {
+	if shared, ok := p.httpClient.Transport.(*sharedTransport); ok {
+		shared.hold()
+	}
+}
+
+// releaseTransport stops holding the shared transport of the poller, if any
+func (p *pagePoller) releaseTransport() {
+	if shared, ok := p.httpClient.Transport.(*sharedTransport); ok {
+		shared.release()
+	}
+}
+
+// closeTransport releases the transport of the poller when it stops. The
+// idle connections of a dedicated transport are closed, while the default
+// one is left as it is, as it is used by everyone.
+func (p *pagePoller) closeTransport() {
+	switch transport := p.httpClient.Transport.(type) {
+	case nil:
+	case *sharedTransport:
+		transport.release()
+	default:
+		p.httpClient.CloseIdleConnections()
+	}
+}
+
+// restore loads the state of the last polls from the store, if any
+func (p *pagePoller) restore() {
+	p.lock.Lock()
+	store := p.store
+	p.lock.Unlock()
+	if store == nil {
+		return
+	}
+
+	records, err := store.List(p.id, 0)
+	if err != nil {
+		log.Error().Str("id", p.id).Err(err).Msg("could not restore state from store")
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if records[0].UserAgentIndex < len(p.userAgents) {
+		p.lastUAIndex = records[0].UserAgentIndex
+	}
+
+	if !p.detect {
+		return
+	}
+
+	// -- Failed polls have no hash, so look for the last successful one
+	for _, r := range records {
+		if len(r.Hash) > 0 {
+			p.lastHash = r.Hash
+			return
+		}
+	}
 }
 
 // GetID returns the ID of this poller. If the `Page` struct provided
@@ -0,0 +1,166 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicIsolation(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	p, err := New(&Page{URL: server.URL, PollOptions: &PollOptions{Frequency: Duration(5 * time.Second), MaxPanics: 2}})
+	a.NoError(err)
+
+	var (
+		lock   sync.Mutex
+		errs   []error
+		panics = true
+	)
+	p.SetErrorFunc(func(id string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		a.Equal(p.GetID(), id)
+		errs = append(errs, err)
+	})
+	p.SetHandlerFunc(func(res *Result) {
+		lock.Lock()
+		defer lock.Unlock()
+		if panics {
+			panic(io.ErrUnexpectedEOF)
+		}
+	})
+
+	poll := p.(*pagePoller).poll
+	a.NotPanics(func() { poll(context.Background()) })
+	a.Len(errs, 1)
+	var panicErr *PanicError
+	a.True(errors.As(errs[0], &panicErr))
+	a.Equal(io.ErrUnexpectedEOF, panicErr.Value)
+	a.NotEmpty(panicErr.Stack)
+	a.True(errors.Is(errs[0], io.ErrUnexpectedEOF))
+
+	// -- A poll without panics resets the streak
+	panics = false
+	poll(context.Background())
+	a.Equal(Stats{Polls: 2, Panics: 1}, p.Stats())
+
+	panics = true
+	poll(context.Background())
+	poll(context.Background())
+	a.Len(errs, 4)
+	a.True(errors.Is(errs[3], ErrPollerDisabled))
+	a.Equal(Stats{Polls: 4, Panics: 3, ConsecutivePanics: 2, Disabled: true}, p.Stats())
+
+	// -- A disabled poller stops polling
+	done := make(chan struct{})
+	go func() {
+		p.Start(context.Background(), false)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("disabled poller did not stop")
+	}
+}
+
+func TestBodyLifecycle(t *testing.T) {
+	a := assert.New(t)
+
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	poll := func(maxBodySize int64, handler HandlerFunc) {
+		p, err := New(&Page{URL: server.URL, MaxBodySize: maxBodySize})
+		a.NoError(err)
+		p.SetHandlerFunc(handler)
+		p.(*pagePoller).poll(context.Background())
+	}
+
+	poll(10, func(res *Result) {
+		a.Equal(strings.Repeat("a", 10), string(res.Body))
+		a.True(res.Truncated)
+		body, err := ioutil.ReadAll(res.Response.Body)
+		a.NoError(err)
+		a.Equal(res.Body, body)
+	})
+
+	// -- Bodies are drained and closed even with no handler, so the same
+	// connection is reused all along
+	p, err := New(&Page{URL: server.URL})
+	a.NoError(err)
+	for i := 0; i < 3; i++ {
+		p.(*pagePoller).poll(context.Background())
+	}
+	poll(0, func(res *Result) {
+		a.Len(res.Body, 100)
+		a.False(res.Truncated)
+	})
+	a.Equal(int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestConcurrentPolls(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		lock   sync.Mutex
+		agents = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		agents[r.UserAgent()]++
+	}))
+	defer server.Close()
+
+	p, err := New(&Page{
+		URL:              server.URL,
+		UserAgentOptions: &UserAgentOptions{UserAgents: []string{"one", "two"}},
+	})
+	a.NoError(err)
+
+	// -- Polls and setters run alongside each other, i.e. through the
+	// admin API: the race detector complains if they are not guarded
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			p.Poll(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			p.SetHeaderFunc(func(string, http.Header) {})
+			p.SetAuthenticator(nil)
+			p.SetStore(NewMemoryStore(StoreOptions{}))
+		}()
+	}
+	wg.Wait()
+
+	// -- User agents are still rotated
+	a.Equal(map[string]int{"one": 5, "two": 5}, agents)
+}
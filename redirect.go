@@ -0,0 +1,194 @@
+package websitepoller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultMaxRedirects int = 10
+	// RedirectMethodKeep repeats the request with the same method and body
+	// after 301, 302, 307 and 308 redirects. 303 is always followed with a
+	// GET.
+	RedirectMethodKeep string = "keep"
+	// RedirectMethodGet follows all redirects with a GET without body,
+	// including 307 and 308
+	RedirectMethodGet string = "get"
+)
+
+// alwaysStripped are the headers removed from requests redirected to a
+// different host, besides the ones of the redirect policy
+var alwaysStripped = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Redirect is a redirect followed while polling
+type Redirect struct {
+	// URL the request was redirected to
+	URL string `json:"url"`
+	// StatusCode of the response that redirected the request, i.e. 301
+	StatusCode int `json:"statusCode"`
+}
+
+// redirectPolicy decides which redirects are followed and how
+type redirectPolicy struct {
+	maxHops      int
+	sameHost     bool
+	domains      []string
+	method       string
+	stripHeaders []string
+}
+
+type redirectsKey struct{}
+
+// redirectChain collects the redirects followed by a request. Redirects are
+// followed one after the other, but the lock keeps the race detector happy
+// when the chain is read by another goroutine.
+type redirectChain struct {
+	redirects []Redirect
+	lock      sync.Mutex
+}
+
+func (c *redirectChain) add(r Redirect) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.redirects = append(c.redirects, r)
+}
+
+func (c *redirectChain) list() []Redirect {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.redirects
+}
+
+// withRedirectChain returns a context that collects the redirects followed
+// by the request in chain
+func withRedirectChain(ctx context.Context, chain *redirectChain) context.Context {
+	return context.WithValue(ctx, redirectsKey{}, chain)
+}
+
+// parseRedirectPolicy returns the function deciding whether to follow
+// redirects, for http.Client.CheckRedirect
+func parseRedirectPolicy(id string, follow bool, opts *RedirectPolicy) (func(*http.Request, []*http.Request) error, error) {
+	policy := &redirectPolicy{maxHops: defaultMaxRedirects}
+	if opts != nil {
+		if opts.MaxHops < 0 {
+			return nil, fmt.Errorf("%w: max hops cannot be negative", ErrInvalidRedirectPolicy)
+		}
+		if opts.MaxHops > 0 {
+			policy.maxHops = opts.MaxHops
+		}
+
+		for _, d := range opts.AllowedDomains {
+			d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), ".")
+			if len(d) == 0 {
+				return nil, fmt.Errorf("%w: empty allowed domain", ErrInvalidRedirectPolicy)
+			}
+			policy.domains = append(policy.domains, d)
+		}
+
+		switch m := strings.ToLower(opts.Method); m {
+		case "", RedirectMethodKeep, RedirectMethodGet:
+			policy.method = m
+		default:
+			return nil, fmt.Errorf("%w: unsupported method rule %s", ErrInvalidRedirectPolicy, opts.Method)
+		}
+
+		policy.sameHost = opts.SameHost
+		policy.stripHeaders = opts.StripHeaders
+	}
+
+	if !follow {
+		if opts != nil {
+			log.Warn().Str("id", id).Msg("redirect policy provided but followRedirect is false, redirects will not be followed")
+		}
+		return func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}, nil
+	}
+
+	return policy.check, nil
+}
+
+// check decides whether to follow the redirect to req and adjusts it
+// according to the policy. Redirects to hosts that are not allowed are not
+// followed and their response is returned.
+func (p *redirectPolicy) check(req *http.Request, via []*http.Request) error {
+	if len(via) >= p.maxHops {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, p.maxHops)
+	}
+
+	first := via[0]
+	if !p.allowed(first.URL.Hostname(), req.URL.Hostname()) {
+		log.Warn().Str("url", req.URL.String()).Msg("redirect not allowed by policy, not following it")
+		return http.ErrUseLastResponse
+	}
+
+	// -- req.Response is the redirect being followed
+	code := 0
+	if req.Response != nil {
+		code = req.Response.StatusCode
+	}
+	p.rewriteMethod(req, via[len(via)-1], code)
+
+	if !strings.EqualFold(first.URL.Hostname(), req.URL.Hostname()) {
+		for _, h := range alwaysStripped {
+			req.Header.Del(h)
+		}
+		for _, h := range p.stripHeaders {
+			req.Header.Del(h)
+		}
+	}
+
+	if chain, ok := req.Context().Value(redirectsKey{}).(*redirectChain); ok {
+		chain.add(Redirect{URL: req.URL.String(), StatusCode: code})
+	}
+
+	return nil
+}
+
+// allowed returns true if a redirect from the host of the page to host is
+// allowed
+func (p *redirectPolicy) allowed(from, host string) bool {
+	if !p.sameHost && len(p.domains) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	if p.sameHost && host == strings.ToLower(from) {
+		return true
+	}
+	for _, d := range p.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rewriteMethod changes the method and body of the redirected request
+// according to the policy. By default, the HTTP client keeps them for 307
+// and 308 and switches to GET for the others.
+func (p *redirectPolicy) rewriteMethod(req, prev *http.Request, code int) {
+	switch {
+	case p.method == RedirectMethodGet && req.Method != http.MethodGet && req.Method != http.MethodHead:
+		req.Method = http.MethodGet
+		req.Body, req.GetBody, req.ContentLength = nil, nil, 0
+		req.Header.Del("Content-Type")
+	case p.method == RedirectMethodKeep && (code == http.StatusMovedPermanently || code == http.StatusFound) && req.Method != prev.Method:
+		req.Method = prev.Method
+		if prev.GetBody != nil {
+			body, err := prev.GetBody()
+			if err != nil {
+				log.Error().Err(err).Msg("could not get body to repeat after redirect")
+				return
+			}
+			req.Body, req.GetBody, req.ContentLength = body, prev.GetBody, prev.ContentLength
+			if ct := prev.Header.Get("Content-Type"); len(ct) > 0 {
+				req.Header.Set("Content-Type", ct)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	type received struct {
+		method, body, contentType string
+		header                    http.Header
+	}
+	var last received
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		last = received{method: r.Method, body: string(body), contentType: r.Header.Get("Content-Type"), header: r.Header.Clone()}
+	}
+
+	// -- Same port, but a different host for the redirect policy
+	other := httptest.NewServer(http.HandlerFunc(echo))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/307":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/302":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, otherURL+"/echo", http.StatusFound)
+		default:
+			echo(w, r)
+		}
+	}))
+	defer server.Close()
+
+	post := http.MethodPost
+	poll := func(path string, policy *RedirectPolicy, page *Page) *Result {
+		if page == nil {
+			page = &Page{}
+		}
+		page.URL, page.FollowRedirect, page.RedirectPolicy = server.URL+path, true, policy
+
+		p, err := New(page)
+		a.NoError(err)
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		return res
+	}
+
+	// -- The chain is recorded
+	last = received{}
+	res := poll("/a", nil, nil)
+	a.NoError(res.Err)
+	a.Equal([]Redirect{
+		{URL: server.URL + "/b", StatusCode: http.StatusMovedPermanently},
+		{URL: server.URL + "/echo", StatusCode: http.StatusFound},
+	}, res.Redirects)
+	a.Equal(res.Redirects, Summarize(res, false).Redirects)
+	a.Equal(http.MethodGet, last.method)
+
+	res = poll("/a", &RedirectPolicy{MaxHops: 1}, nil)
+	a.True(errors.Is(res.Err, ErrTooManyRedirects))
+
+	// -- Allowed hosts
+	res = poll("/away", &RedirectPolicy{SameHost: true}, nil)
+	a.NoError(res.Err)
+	a.Equal(http.StatusFound, res.Response.StatusCode)
+	a.Empty(res.Redirects)
+
+	res = poll("/a", &RedirectPolicy{SameHost: true}, nil)
+	a.Equal(http.StatusOK, res.Response.StatusCode)
+	a.Len(res.Redirects, 2)
+
+	res = poll("/away", &RedirectPolicy{SameHost: true, AllowedDomains: []string{".LOCALHOST"}}, nil)
+	a.Equal(http.StatusOK, res.Response.StatusCode)
+	a.Equal([]Redirect{{URL: otherURL + "/echo", StatusCode: http.StatusFound}}, res.Redirects)
+
+	res = poll("/away", &RedirectPolicy{AllowedDomains: []string{"example.com"}}, nil)
+	a.Equal(http.StatusFound, res.Response.StatusCode)
+
+	// -- Headers are stripped on cross-host redirects only
+	headers := Headers{
+		{Key: "Authorization", Values: []string{"Bearer secret"}},
+		{Key: "X-Api-Key", Values: []string{"key"}},
+		{Key: "Accept", Values: []string{"*/*"}},
+	}
+	poll("/away", &RedirectPolicy{StripHeaders: []string{"x-api-key"}}, &Page{Headers: headers})
+	a.Empty(last.header.Get("Authorization"))
+	a.Empty(last.header.Get("X-Api-Key"))
+	a.Equal("*/*", last.header.Get("Accept"))
+
+	poll("/302", &RedirectPolicy{StripHeaders: []string{"x-api-key"}}, &Page{Headers: headers})
+	a.Equal("Bearer secret", last.header.Get("Authorization"))
+	a.Equal("key", last.header.Get("X-Api-Key"))
+
+	// -- Method and body
+	form := Headers{{Key: "Content-Type", Values: []string{"text/plain"}}}
+	poll("/307", nil, &Page{Method: &post, Body: "hello", Headers: form})
+	a.Equal(received{method: post, body: "hello", contentType: "text/plain"}, received{last.method, last.body, last.contentType, nil})
+
+	poll("/307", &RedirectPolicy{Method: "get"}, &Page{Method: &post, Body: "hello", Headers: form})
+	a.Equal(received{method: http.MethodGet}, received{last.method, last.body, last.contentType, nil})
+
+	poll("/302", nil, &Page{Method: &post, Body: "hello", Headers: form})
+	a.Equal(http.MethodGet, last.method)
+	a.Empty(last.body)
+
+	poll("/302", &RedirectPolicy{Method: "Keep"}, &Page{Method: &post, Body: "hello", Headers: form})
+	a.Equal(received{method: post, body: "hello", contentType: "text/plain"}, received{last.method, last.body, last.contentType, nil})
+
+	// -- Policies are ignored unless redirects are followed
+	p, err := New(&Page{URL: server.URL + "/a", RedirectPolicy: &RedirectPolicy{MaxHops: 5}})
+	a.NoError(err)
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+	a.Equal(http.StatusMovedPermanently, res.Response.StatusCode)
+	a.Empty(res.Redirects)
+}
+
+func TestParseRedirectPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	for _, policy := range []*RedirectPolicy{
+		{MaxHops: -1},
+		{AllowedDomains: []string{"example.com", " . "}},
+		{Method: "post"},
+	} {
+		err := Validate(&Page{URL: "https://example.com", FollowRedirect: true, RedirectPolicy: policy})
+		a.True(errors.Is(err, ErrInvalidRedirectPolicy))
+		a.Contains(err.Error(), "redirectPolicy: ")
+	}
+}
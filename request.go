@@ -0,0 +1,99 @@
+package websitepoller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// requestTemplate contains everything needed to build a request. URL,
+// headers and body may contain variables, which are expanded each time a
+// request is built.
+type requestTemplate struct {
+	method  string
+	url     string
+	headers Headers
+	body    string
+}
+
+func newRequestTemplate(method, rawurl string, headers Headers, body string) *requestTemplate {
+	return &requestTemplate{
+		method:  method,
+		url:     rawurl,
+		headers: headers,
+		body:    body,
+	}
+}
+
+// vars returns the names of all the variables referenced by the template
+func (t *requestTemplate) vars() ([]string, error) {
+	names, err := templateVars(t.url)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := []string{t.body}
+	for _, header := range t.headers {
+		texts = append(texts, header.Key)
+		texts = append(texts, header.Values...)
+	}
+
+	for _, text := range texts {
+		found, err := templateVars(text)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, found...)
+	}
+
+	return names, nil
+}
+
+// build returns a new request, with all variables expanded with lookup
+func (t *requestTemplate) build(ctx context.Context, lookup func(string) (string, error)) (*http.Request, error) {
+	rawURL, err := expandTemplate(t.url, lookup)
+	if err != nil {
+		return nil, err
+	}
+	body, err := expandTemplate(t.body, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if len(body) > 0 {
+		req, err = http.NewRequestWithContext(ctx, t.method, rawURL, strings.NewReader(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, t.method, rawURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, header := range t.headers {
+		key, err := expandTemplate(header.Key, lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		// -- A header with no values is deleted. Keeping the key with no
+		// values prevents the transport from adding a default one, i.e.
+		// the User-Agent.
+		if len(header.Values) == 0 {
+			req.Header.Del(key)
+			req.Header[http.CanonicalHeaderKey(key)] = nil
+			continue
+		}
+
+		for _, val := range header.Values {
+			val, err := expandTemplate(val, lookup)
+			if err != nil {
+				return nil, err
+			}
+			// Keys are set as they are, without canonicalizing them
+			req.Header[key] = append(req.Header[key], val)
+		}
+	}
+
+	return req, nil
+}
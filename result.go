@@ -0,0 +1,120 @@
+package websitepoller
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result contains the outcome of a single poll
+type Result struct {
+	// ID of the poller that performed the request
+	ID string
+	// Time when the poll started
+	Time time.Time
+	// Duration of the request, until the headers of the response were
+	// received
+	Duration time.Duration
+	// Response returned by the website. This is nil if the request failed.
+	Response *http.Response
+	// Err is the error occurred while polling, if any. It is a *PollError,
+	// so that the kind of failure can be checked with errors.Is, i.e.
+	// errors.Is(res.Err, ErrTimeout).
+	Err error
+	// Body of the response, read by the poller up to the maximum size
+	// defined in the page. The body of Response is already closed and
+	// replaced with one reading these bytes, so handlers do not need to
+	// close it.
+	Body []byte
+	// Truncated specifies whether the body was larger than the maximum size
+	// and was truncated
+	Truncated bool
+	// Redirects contains the redirects followed, in order. Response is the
+	// response of the last one.
+	Redirects []Redirect
+	// ContentEncoding is the original Content-Encoding of the response, if
+	// the poller decoded it. This only happens when the page sets its own
+	// Accept-Encoding header, as otherwise the HTTP client asks for gzip
+	// and decodes it by itself. Body and Response always contain the
+	// decoded body.
+	ContentEncoding string
+	// Charset is the original charset of the body, if it was converted to
+	// UTF-8, i.e. shift_jis or windows-1252. Note that ISO-8859-1 is
+	// reported as windows-1252, as browsers do.
+	Charset string
+	// Timings contains the duration of each phase of the request, or nil if
+	// tracing is not enabled. If the request failed, the phases that were
+	// not reached are zero.
+	Timings *Timings
+	// Fields contains the values extracted by the extractors defined in
+	// the page, indexed by their name. Extractors that did not match
+	// anything are not included.
+	Fields map[string]string
+	// Hash is the hash of the content used for change detection, in
+	// hexadecimal format. This is empty if change detection is not enabled.
+	Hash string
+	// Changed specifies whether the content used for change detection is
+	// different from the one of the previous successful poll. This is
+	// always false on the first poll.
+	Changed bool
+	// Snapshot contains the snapshot of the body archived on disk, or nil
+	// if snapshots are not enabled or the body could not be archived.
+	Snapshot *Snapshot
+}
+
+// Summary is a JSON-friendly summary of a result
+type Summary struct {
+	// ID of the poller that performed the request
+	ID string `json:"id"`
+	// Time when the poll started
+	Time time.Time `json:"time"`
+	// LatencyMS is the duration of the request, in milliseconds
+	LatencyMS float64 `json:"latencyMs"`
+	// StatusCode of the response, or 0 if the request failed
+	StatusCode int `json:"statusCode,omitempty"`
+	// Error occurred while polling, if any
+	Error string `json:"error,omitempty"`
+	// Fields contains the extracted values
+	Fields map[string]string `json:"fields,omitempty"`
+	// Hash is the hash used for change detection
+	Hash string `json:"hash,omitempty"`
+	// Changed specifies whether a change was detected
+	Changed bool `json:"changed,omitempty"`
+	// Body of the response, only included if requested. It is encoded in
+	// base64 in JSON.
+	Body []byte `json:"body,omitempty"`
+	// Truncated specifies whether the body was truncated
+	Truncated bool `json:"truncated,omitempty"`
+	// Redirects contains the redirects followed
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// Timings contains the duration of each phase of the request, if
+	// tracing is enabled
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// Summarize returns a summary of the result. The body is only included if
+// withBody is true.
+func Summarize(res *Result, withBody bool) *Summary {
+	s := &Summary{
+		ID:        res.ID,
+		Time:      res.Time,
+		LatencyMS: float64(res.Duration) / float64(time.Millisecond),
+		Fields:    res.Fields,
+		Hash:      res.Hash,
+		Changed:   res.Changed,
+		Truncated: res.Truncated,
+		Redirects: res.Redirects,
+		Timings:   res.Timings,
+	}
+
+	if res.Err != nil {
+		s.Error = res.Err.Error()
+	}
+	if res.Response != nil {
+		s.StatusCode = res.Response.StatusCode
+	}
+	if withBody {
+		s.Body = res.Body
+	}
+
+	return s
+}
@@ -0,0 +1,216 @@
+package websitepoller
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultResultsBuffer int = 16
+)
+
+// ResultsPolicy defines what happens when a result does not fit in the
+// buffer of a results channel, because the consumer is falling behind
+type ResultsPolicy int
+
+const (
+	// DropNewest discards the new result and keeps the buffered ones. This
+	// is the default.
+	DropNewest ResultsPolicy = iota
+	// DropOldest discards the oldest buffered result to make room for the
+	// new one
+	DropOldest
+	// Block waits for the consumer to make room. The scheduled polls are
+	// skipped while a result is waiting, so that the poller slows down to
+	// the pace of the consumer.
+	Block
+)
+
+// ResultsOptions contains options about results channels
+type ResultsOptions struct {
+	// Buffer is the number of results that are buffered while the consumer
+	// is busy. Defaults to 16.
+	Buffer int
+	// Policy defines what happens when the buffer is full. Defaults to
+	// DropNewest.
+	Policy ResultsPolicy
+}
+
+// resultsChan is a buffered channel of results that can be closed while
+// results are being sent on it
+type resultsChan struct {
+	ch        chan Result
+	done      chan struct{}
+	policy    ResultsPolicy
+	closed    bool
+	closeOnce sync.Once
+	// blocked is the number of senders waiting for the consumer
+	blocked int32
+	// lock guards sends against closing the channel: senders hold it for
+	// reading
+	lock sync.RWMutex
+}
+
+func newResultsChan(opts ResultsOptions) *resultsChan {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultResultsBuffer
+	}
+
+	return &resultsChan{
+		ch:     make(chan Result, opts.Buffer),
+		done:   make(chan struct{}),
+		policy: opts.Policy,
+	}
+}
+
+// send sends the result according to the policy and returns false if a
+// result was dropped, either this one or an older one
+func (r *resultsChan) send(ctx context.Context, res Result) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.closed {
+		return false
+	}
+
+	switch r.policy {
+	case Block:
+		select {
+		case r.ch <- res:
+			return true
+		default:
+		}
+
+		atomic.AddInt32(&r.blocked, 1)
+		defer atomic.AddInt32(&r.blocked, -1)
+		select {
+		case r.ch <- res:
+			return true
+		case <-r.done:
+		case <-ctx.Done():
+		}
+		return false
+	case DropOldest:
+		dropped := false
+		for {
+			select {
+			case r.ch <- res:
+				return !dropped
+			default:
+			}
+
+			select {
+			case <-r.ch:
+				dropped = true
+			default:
+			}
+		}
+	default:
+		select {
+		case r.ch <- res:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// isBlocked returns true if a sender is waiting for the consumer
+func (r *resultsChan) isBlocked() bool {
+	return atomic.LoadInt32(&r.blocked) > 0
+}
+
+// close closes the channel, after waking up the senders that are blocked
+func (r *resultsChan) close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.closed = true
+		close(r.ch)
+	})
+}
+
+// copyResult returns a copy of the result with its own response, whose body
+// reads the body of the result from the start
+func copyResult(res *Result) Result {
+	copied := *res
+	if res.Response != nil {
+		resp := *res.Response
+		resp.Header = res.Response.Header.Clone()
+		resp.Body = http.NoBody
+		if res.Body != nil {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(res.Body))
+		}
+		copied.Response = &resp
+	}
+
+	return copied
+}
+
+// Results returns a channel where the results of the polls are sent, as an
+// alternative or in addition to the handler func. Results are sent before
+// the handler func is called and do not pass through middlewares. The
+// channel is closed when the poller stops, after which a new one is
+// returned. Set the options with SetResultsOptions before calling this.
+func (p *pagePoller) Results() <-chan Result {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.results == nil {
+		p.results = newResultsChan(p.resultsOpts)
+	}
+
+	return p.results.ch
+}
+
+// SetResultsOptions sets the options of the results channel. They are used
+// for the channels created from now on.
+func (p *pagePoller) SetResultsOptions(opts ResultsOptions) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.resultsOpts = opts
+}
+
+// publishResult sends the result on the results channel, if any
+func (p *pagePoller) publishResult(ctx context.Context, res *Result) {
+	p.lock.Lock()
+	results := p.results
+	p.lock.Unlock()
+	if results == nil {
+		return
+	}
+
+	if !results.send(ctx, copyResult(res)) {
+		p.stats.recordDroppedResult()
+		log.Warn().Str("id", p.id).Msg("results channel is full, dropping result")
+	}
+}
+
+// resultsBlocked returns true if a poll is waiting for the consumer of the
+// results channel to make room
+func (p *pagePoller) resultsBlocked() bool {
+	p.lock.Lock()
+	results := p.results
+	p.lock.Unlock()
+
+	return results != nil && results.isBlocked()
+}
+
+// closeResults closes the results channel, if any
+func (p *pagePoller) closeResults() {
+	p.lock.Lock()
+	results := p.results
+	p.results = nil
+	p.lock.Unlock()
+
+	if results != nil {
+		results.close()
+	}
+}
@@ -0,0 +1,193 @@
+package websitepoller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsChannel(t *testing.T) {
+	a := assert.New(t)
+
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "poll %d", atomic.AddInt32(&count, 1))
+	}))
+	defer server.Close()
+
+	newPoller := func(opts ResultsOptions) *pagePoller {
+		atomic.StoreInt32(&count, 0)
+		p, err := New(&Page{URL: server.URL})
+		a.NoError(err)
+		p.SetResultsOptions(opts)
+		return p.(*pagePoller)
+	}
+	bodies := func(results <-chan Result) []string {
+		list := []string{}
+		for res := range results {
+			body, err := io.ReadAll(res.Response.Body)
+			a.NoError(err)
+			list = append(list, string(body))
+		}
+		return list
+	}
+	stop := func(p *pagePoller) {
+		ctx, canc := context.WithCancel(context.Background())
+		canc()
+		p.Start(ctx, false)
+	}
+
+	// -- Drop newest
+	p := newPoller(ResultsOptions{Buffer: 1})
+	results := p.Results()
+	handled := 0
+	p.SetHandlerFunc(func(res *Result) {
+		// -- Handlers read their own copy of the body
+		io.ReadAll(res.Response.Body)
+		handled++
+	})
+	for i := 0; i < 3; i++ {
+		p.poll(context.Background())
+	}
+	a.Equal(3, handled)
+	a.Equal(uint64(2), p.Stats().DroppedResults)
+	stop(p)
+	a.Equal([]string{"poll 1"}, bodies(results))
+
+	// -- A new channel is returned after the poller stops
+	a.NotEqual(results, p.Results())
+
+	// -- Drop oldest
+	p = newPoller(ResultsOptions{Buffer: 2, Policy: DropOldest})
+	results = p.Results()
+	for i := 0; i < 3; i++ {
+		p.poll(context.Background())
+	}
+	a.Equal(uint64(1), p.Stats().DroppedResults)
+	stop(p)
+	a.Equal([]string{"poll 2", "poll 3"}, bodies(results))
+
+	// -- Block
+	p = newPoller(ResultsOptions{Buffer: 1, Policy: Block})
+	results = p.Results()
+	p.poll(context.Background())
+	polled := make(chan struct{})
+	go func() {
+		p.poll(context.Background())
+		close(polled)
+	}()
+	select {
+	case <-polled:
+		a.Fail("poll should block until the result is read")
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Equal("poll 1", bodies(limit(results, 1))[0])
+	<-polled
+	a.Equal(uint64(0), p.Stats().DroppedResults)
+
+	// -- Blocked polls are released when the poller stops
+	ctx, canc := context.WithCancel(context.Background())
+	polled = make(chan struct{})
+	go func() {
+		p.poll(ctx)
+		close(polled)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	canc()
+	<-polled
+	a.Equal(uint64(1), p.Stats().DroppedResults)
+	stop(p)
+	a.Equal([]string{"poll 2"}, bodies(results))
+}
+
+func TestResultsChannelBlockSkipsPolls(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	p, err := New(&Page{URL: server.URL, PollOptions: &PollOptions{
+		Frequency:    Duration(100 * time.Millisecond),
+		MinFrequency: Duration(100 * time.Millisecond),
+	}})
+	a.NoError(err)
+	p.SetResultsOptions(ResultsOptions{Buffer: 1, Policy: Block})
+	results := p.Results()
+
+	// -- The first result fills the buffer, the second one waits for the
+	// consumer and the polls scheduled in the meantime are skipped
+	ctx, canc := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		p.Start(ctx, true)
+		close(stopped)
+	}()
+	time.Sleep(650 * time.Millisecond)
+	a.Equal(uint64(2), p.Stats().Polls)
+
+	// -- Polls resume once there is room
+	<-results
+	a.Eventually(func() bool { return p.Stats().Polls > 2 }, time.Second, 10*time.Millisecond)
+
+	canc()
+	<-stopped
+}
+
+func TestManagerResults(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	newPoller := func(id string) Poller {
+		p, err := New(&Page{ID: &id, URL: server.URL, PollOptions: &PollOptions{Frequency: Duration(time.Minute)}})
+		a.NoError(err)
+		return p
+	}
+
+	m := NewManager()
+	m.SetResultsOptions(ResultsOptions{Policy: Block})
+	a.NoError(m.Add(newPoller("one"), newPoller("two")))
+	results := m.Results()
+
+	ctx, canc := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		m.Start(ctx, true)
+		close(stopped)
+	}()
+
+	ids := []string{}
+	for _, res := range []Result{<-results, <-results} {
+		ids = append(ids, res.ID)
+	}
+
+	// -- Pollers added while running are forwarded too
+	a.NoError(m.Add(newPoller("three")))
+	ids = append(ids, (<-results).ID)
+	sort.Strings(ids)
+	a.Equal([]string{"one", "three", "two"}, ids)
+
+	canc()
+	<-stopped
+	_, open := <-results
+	a.False(open)
+}
+
+// limit returns a channel with the first n results of results
+func limit(results <-chan Result, n int) <-chan Result {
+	limited := make(chan Result, n)
+	for i := 0; i < n; i++ {
+		limited <- <-results
+	}
+	close(limited)
+	return limited
+}
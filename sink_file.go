@@ -0,0 +1,331 @@
+package websitepoller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileSinkTimeLayout string = "20060102T150405.000000000Z"
+	defaultBodyExt     string = ".body"
+)
+
+// FileSinkOptions contains options about the file sink
+type FileSinkOptions struct {
+	// Path of the file where results are written, one JSON line for each
+	// of them. The directory is created if it does not exist.
+	Path string
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// means no limit.
+	MaxSize int64
+	// RotateEvery is the time after which the file is rotated, regardless
+	// of its size. Zero means no limit.
+	RotateEvery time.Duration
+	// MaxBackups is the maximum number of rotated files to keep. Older
+	// files are deleted first. Zero means keep all of them.
+	MaxBackups int
+	// Compress specifies whether rotated files should be compressed with
+	// gzip
+	Compress bool
+	// BodiesDir is the directory where response bodies are saved, in a
+	// sub directory for each poller and with the time of the poll as file
+	// name. The path of each body is written in the bodyFile field of its
+	// line. If empty, bodies are not saved.
+	BodiesDir string
+}
+
+// FileSink writes a JSON summary of each result to a file, one for each
+// line, and rotates it when it gets too big or too old. Use its Handle method
+// as HandlerFunc, or call it from your own handler.
+type FileSink struct {
+	opts     FileSinkOptions
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+	lock     sync.Mutex
+	rotating sync.WaitGroup
+	cleanup  sync.Mutex
+}
+
+type fileSinkLine struct {
+	*Summary
+	BodyFile string `json:"bodyFile,omitempty"`
+}
+
+// NewFileSink returns a new file sink. Results are appended to the file if
+// it already exists. Call Close to release the file.
+func NewFileSink(opts FileSinkOptions) (*FileSink, error) {
+	if len(opts.Path) == 0 {
+		return nil, fmt.Errorf("%w: no file path provided", ErrInvalidSinkOptions)
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0755); err != nil {
+		return nil, err
+	}
+	if len(opts.BodiesDir) > 0 {
+		if err := os.MkdirAll(opts.BodiesDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &FileSink{opts: opts}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Handle writes the result to the file. It can be used as HandlerFunc.
+func (s *FileSink) Handle(res *Result) {
+	l := log.With().Str("id", res.ID).Logger()
+	line := fileSinkLine{Summary: Summarize(res, false)}
+
+	if len(s.opts.BodiesDir) > 0 {
+		path, err := s.saveBody(res)
+		if err != nil {
+			l.Error().Err(err).Msg("could not save body")
+		}
+		line.BodyFile = path
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		l.Error().Err(err).Msg("could not encode result")
+		return
+	}
+	data = append(data, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		l.Warn().Msg("file sink is closed, dropping result")
+		return
+	}
+
+	if s.shouldRotate(len(data)) {
+		if err := s.rotate(); err != nil {
+			l.Error().Err(err).Msg("could not rotate file")
+		}
+	}
+
+	if s.file == nil {
+		// -- Rotation failed to open a new file: try again
+		if err := s.open(); err != nil {
+			l.Error().Err(err).Msg("could not open file, dropping result")
+			return
+		}
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		l.Error().Err(err).Msg("could not write result")
+	}
+}
+
+// Close closes the file and waits for rotated files to be compressed
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.rotating.Wait()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// open opens the file. It must be called with the lock held or before the
+// sink is used.
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file, s.size, s.openedAt = f, info.Size(), time.Now()
+	return nil
+}
+
+func (s *FileSink) shouldRotate(next int) bool {
+	if s.file == nil || s.size == 0 {
+		return false
+	}
+
+	if s.opts.MaxSize > 0 && s.size+int64(next) > s.opts.MaxSize {
+		return true
+	}
+
+	return s.opts.RotateEvery > 0 && time.Since(s.openedAt) >= s.opts.RotateEvery
+}
+
+// rotate renames the current file and opens a new one. Compression and
+// deletion of old files happen in background. It must be called with the
+// lock held.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	ext := filepath.Ext(s.opts.Path)
+	base := strings.TrimSuffix(s.opts.Path, ext)
+	rotated := fmt.Sprintf("%s-%s%s", base, time.Now().UTC().Format(fileSinkTimeLayout), ext)
+	if err := os.Rename(s.opts.Path, rotated); err != nil {
+		return err
+	}
+
+	s.rotating.Add(1)
+	go func() {
+		defer s.rotating.Done()
+
+		// -- One at a time, so that old files are not removed while
+		// being compressed
+		s.cleanup.Lock()
+		defer s.cleanup.Unlock()
+
+		if s.opts.Compress {
+			if err := gzipFile(rotated); err != nil {
+				log.Error().Err(err).Str("file", rotated).Msg("could not compress rotated file")
+			}
+		}
+		s.removeOldBackups(base, ext)
+	}()
+
+	return s.open()
+}
+
+func (s *FileSink) removeOldBackups(base, ext string) {
+	if s.opts.MaxBackups <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return
+	}
+
+	// -- Timestamps in names sort in chronological order
+	sort.Strings(backups)
+	for i := 0; i < len(backups)-s.opts.MaxBackups; i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			log.Error().Err(err).Str("file", backups[i]).Msg("could not remove rotated file")
+		}
+	}
+}
+
+// saveBody writes the body of the response in the directory of the poller
+// and returns its path. If the result has no body, i.e. because it was
+// built by hand, the body of the response is read and replaced so that it
+// can still be read by other handlers.
+func (s *FileSink) saveBody(res *Result) (string, error) {
+	body := res.Body
+	if body == nil {
+		if res.Response == nil || res.Response.Body == nil {
+			return "", nil
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(res.Response.Body)
+		res.Response.Body.Close()
+		res.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	dir := filepath.Join(s.opts.BodiesDir, url.PathEscape(res.ID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	at := res.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	path := filepath.Join(dir, at.UTC().Format(fileSinkTimeLayout)+bodyExtension(res))
+	if err := ioutil.WriteFile(path, body, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// bodyExtension returns the file extension for the content type of the
+// response.
+func bodyExtension(res *Result) string {
+	if res.Response == nil {
+		return defaultBodyExt
+	}
+
+	mediaType, _, err := mime.ParseMediaType(res.Response.Header.Get("Content-Type"))
+	if err != nil {
+		return defaultBodyExt
+	}
+
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return defaultBodyExt
+	}
+
+	return exts[0]
+}
+
+// gzipFile compresses the file at path in path.gz and removes it
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,133 @@
+package websitepoller
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "polls.jsonl")
+
+	sink, err := NewFileSink(FileSinkOptions{
+		Path:       path,
+		MaxSize:    300,
+		MaxBackups: 2,
+		Compress:   true,
+		BodiesDir:  filepath.Join(dir, "bodies"),
+	})
+	a.NoError(err)
+
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		sink.Handle(&Result{
+			ID:       "poller/one",
+			Time:     now.Add(time.Duration(i) * time.Second),
+			Duration: 2 * time.Millisecond,
+			Response: &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"price":10}`)),
+			},
+		})
+	}
+	sink.Handle(&Result{ID: "two", Time: now, Err: errors.New("timeout")})
+	a.NoError(sink.Close())
+	sink.Handle(&Result{ID: "two", Time: now})
+
+	// -- Rotated files are compressed and only the most recent are kept
+	backups, err := filepath.Glob(filepath.Join(dir, "logs", "polls-*.jsonl.gz"))
+	a.NoError(err)
+	a.Len(backups, 2)
+
+	f, err := os.Open(backups[0])
+	a.NoError(err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	a.NoError(err)
+	lines := readLines(t, gz)
+	a.NotEmpty(lines)
+
+	current := readLines(t, mustOpen(t, path))
+	last := current[len(current)-1]
+	a.Equal("two", last["id"])
+	a.Equal("timeout", last["error"])
+	a.Nil(last["bodyFile"])
+
+	// -- Bodies
+	first := current[0]
+	a.Equal(float64(200), first["statusCode"])
+	a.Equal(float64(2), first["latencyMs"])
+	bodyFile := first["bodyFile"].(string)
+	a.Equal(filepath.Join(dir, "bodies", "poller%2Fone"), filepath.Dir(bodyFile))
+	a.True(strings.HasPrefix(filepath.Base(bodyFile), "20210304T050"))
+	a.Equal(".json", filepath.Ext(bodyFile))
+	body, err := ioutil.ReadFile(bodyFile)
+	a.NoError(err)
+	a.Equal(`{"price":10}`, string(body))
+
+	bodies, err := ioutil.ReadDir(filepath.Join(dir, "bodies", "poller%2Fone"))
+	a.NoError(err)
+	a.Len(bodies, 10)
+
+	_, err = NewFileSink(FileSinkOptions{})
+	a.True(errors.Is(err, ErrInvalidSinkOptions))
+}
+
+func TestFileSinkRotateEvery(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "polls.jsonl")
+
+	sink, err := NewFileSink(FileSinkOptions{Path: path, RotateEvery: 20 * time.Millisecond})
+	a.NoError(err)
+
+	sink.Handle(&Result{ID: "one"})
+	sink.Handle(&Result{ID: "two"})
+	time.Sleep(30 * time.Millisecond)
+	sink.Handle(&Result{ID: "three"})
+	a.NoError(sink.Close())
+
+	backups, err := filepath.Glob(strings.TrimSuffix(path, ".jsonl") + "-*.jsonl")
+	a.NoError(err)
+	a.Len(backups, 1)
+	a.Len(readLines(t, mustOpen(t, backups[0])), 2)
+	a.Len(readLines(t, mustOpen(t, path)), 1)
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+func readLines(t *testing.T, r io.Reader) []map[string]interface{} {
+	lines := []map[string]interface{}{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,314 @@
+package websitepoller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWebhookBatchSize     int           = 1
+	defaultWebhookFlushInterval time.Duration = 5 * time.Second
+	defaultWebhookMaxRetries    int           = 3
+	defaultWebhookRetryBackoff  time.Duration = time.Second
+	webhookSpoolExt             string        = ".json"
+	webhookSpoolTmpExt          string        = ".tmp"
+)
+
+// WebhookOptions contains options about the webhook sink
+type WebhookOptions struct {
+	// URL where results are sent with a POST request
+	URL string
+	// Headers to send with each request, i.e. for authentication
+	Headers http.Header
+	// IncludeBody specifies whether response bodies are included in the
+	// summaries
+	IncludeBody bool
+	// BatchSize is the number of results sent with each request. Default
+	// is 1
+	BatchSize int
+	// FlushInterval is the maximum time a result waits for its batch to be
+	// complete before it is sent anyway. This is also how often the spool
+	// is retried. Default is 5 seconds
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed request is retried.
+	// Default is 3
+	MaxRetries int
+	// RetryBackoff is the time waited before the first retry, doubled on
+	// each retry. Default is 1 second
+	RetryBackoff time.Duration
+	// SpoolDir is the directory where batches that could not be sent are
+	// saved, to be sent when the receiver is back up. If empty, batches
+	// that could not be sent are dropped.
+	SpoolDir string
+	// Client used to send requests. Default is a client with a 20 seconds
+	// timeout
+	Client *http.Client
+}
+
+// WebhookSink sends a JSON summary of each result to a URL. The body of
+// each request is a JSON array of Summary. Use its Handle method as
+// HandlerFunc, or call it from your own handler.
+type WebhookSink struct {
+	opts    WebhookOptions
+	queue   chan *Summary
+	done    chan struct{}
+	closed  bool
+	lock    sync.RWMutex
+	dropped uint64
+	spools  uint64
+}
+
+// NewWebhookSink returns a new webhook sink and starts sending results in
+// the background. Call Close to send the pending results and stop it.
+func NewWebhookSink(opts WebhookOptions) (*WebhookSink, error) {
+	if _, err := parseURL(opts.URL); err != nil {
+		return nil, err
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultWebhookBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultWebhookFlushInterval
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultWebhookMaxRetries
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultWebhookRetryBackoff
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: time.Duration(defaultHTTPClientTimeout) * time.Second}
+	}
+	if len(opts.SpoolDir) > 0 {
+		if err := os.MkdirAll(opts.SpoolDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &WebhookSink{
+		opts:  opts,
+		queue: make(chan *Summary, opts.BatchSize*4),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+
+	return s, nil
+}
+
+// Handle queues the result to be sent. It can be used as HandlerFunc. It
+// never blocks: if the queue is full, i.e. because the receiver is slow or
+// down, the result is spooled or, without a spool, dropped.
+func (s *WebhookSink) Handle(res *Result) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		log.Warn().Str("id", res.ID).Msg("webhook sink is closed, dropping result")
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	sum := Summarize(res, s.opts.IncludeBody)
+	select {
+	case s.queue <- sum:
+		return
+	default:
+	}
+
+	if len(s.opts.SpoolDir) == 0 {
+		log.Warn().Str("id", res.ID).Msg("webhook queue is full, dropping result")
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	data, err := json.Marshal([]*Summary{sum})
+	if err != nil {
+		log.Error().Err(err).Msg("could not encode result for webhook")
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	s.spool(data, 1)
+}
+
+// Dropped returns the number of results that were neither sent nor
+// spooled, i.e. because the queue was full and there is no spool
+func (s *WebhookSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close sends the pending results and stops the sink
+func (s *WebhookSink) Close() error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.lock.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := []*Summary{}
+	for {
+		select {
+		case sum, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+
+			batch = append(batch, sum)
+			if len(batch) >= s.opts.BatchSize {
+				s.flush(batch)
+				batch = []*Summary{}
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flush(batch)
+				batch = []*Summary{}
+			} else {
+				s.drainSpool()
+			}
+		}
+	}
+}
+
+func (s *WebhookSink) flush(batch []*Summary) {
+	if len(batch) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		log.Error().Err(err).Msg("could not encode results for webhook")
+		return
+	}
+
+	// -- Send what the receiver missed first, so that it gets results in
+	// order
+	s.drainSpool()
+
+	if err := s.sendWithRetries(data); err != nil {
+		log.Error().Err(err).Int("results", len(batch)).Msg("could not send results to webhook")
+		if len(s.opts.SpoolDir) == 0 {
+			atomic.AddUint64(&s.dropped, uint64(len(batch)))
+			return
+		}
+		s.spool(data, len(batch))
+	}
+}
+
+func (s *WebhookSink) sendWithRetries(data []byte) error {
+	backoff := s.opts.RetryBackoff
+
+	var err error
+	for attempt := 0; attempt <= s.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err = s.send(data); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (s *WebhookSink) send(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.opts.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, vals := range s.opts.Headers {
+		req.Header[key] = vals
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// spool saves the batch of n results to be sent later
+func (s *WebhookSink) spool(data []byte, n int) {
+	// -- Names sort in the order batches were spooled. Batches can be
+	// spooled by Handle while the spool is drained, so they are written
+	// under a temporary name first.
+	name := fmt.Sprintf("%020d-%010d", time.Now().UnixNano(), atomic.AddUint64(&s.spools, 1))
+	path := filepath.Join(s.opts.SpoolDir, name)
+	err := ioutil.WriteFile(path+webhookSpoolTmpExt, data, 0644)
+	if err == nil {
+		err = os.Rename(path+webhookSpoolTmpExt, path+webhookSpoolExt)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("could not spool results, dropping them")
+		os.Remove(path + webhookSpoolTmpExt)
+		atomic.AddUint64(&s.dropped, uint64(n))
+	}
+}
+
+// drainSpool sends the spooled batches, oldest first, and stops at the
+// first failure.
+func (s *WebhookSink) drainSpool() {
+	if len(s.opts.SpoolDir) == 0 {
+		return
+	}
+
+	files, err := ioutil.ReadDir(s.opts.SpoolDir)
+	if err != nil {
+		log.Error().Err(err).Msg("could not read spool")
+		return
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), webhookSpoolExt) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(s.opts.SpoolDir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("could not read spooled results")
+			continue
+		}
+
+		if err := s.send(data); err != nil {
+			return
+		}
+		os.Remove(path)
+	}
+}
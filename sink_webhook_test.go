@@ -0,0 +1,183 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSink(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		lock     sync.Mutex
+		down     bool
+		received [][]*Summary
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		a.Equal("application/json", r.Header.Get("Content-Type"))
+		a.Equal("secret", r.Header.Get("X-Token"))
+		var batch []*Summary
+		a.NoError(json.NewDecoder(r.Body).Decode(&batch))
+		received = append(received, batch)
+	}))
+	defer server.Close()
+
+	spool := filepath.Join(t.TempDir(), "spool")
+	sink, err := NewWebhookSink(WebhookOptions{
+		URL:           server.URL,
+		Headers:       http.Header{"X-Token": {"secret"}},
+		IncludeBody:   true,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		SpoolDir:      spool,
+	})
+	a.NoError(err)
+
+	result := func(id string) *Result {
+		return &Result{
+			ID:       id,
+			Time:     time.Now(),
+			Duration: 1500 * time.Microsecond,
+			Response: &http.Response{StatusCode: 200},
+			Fields:   map[string]string{"price": "10"},
+			Body:     []byte("body"),
+		}
+	}
+	spooled := func() int {
+		files, err := ioutil.ReadDir(spool)
+		a.NoError(err)
+		return len(files)
+	}
+	flushed := func(n int) bool {
+		return a.Eventually(func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return len(received) == n
+		}, time.Second, 5*time.Millisecond)
+	}
+
+	// -- Batching
+	sink.Handle(result("one"))
+	sink.Handle(result("two"))
+	flushed(1)
+	lock.Lock()
+	a.Len(received[0], 2)
+	a.Equal("one", received[0][0].ID)
+	a.Equal(200, received[0][0].StatusCode)
+	a.Equal(1.5, received[0][0].LatencyMS)
+	a.Equal("10", received[0][0].Fields["price"])
+	a.Equal([]byte("body"), received[0][0].Body)
+	down = true
+	lock.Unlock()
+
+	// -- Receiver down: the batch is spooled
+	sink.Handle(result("three"))
+	sink.Handle(&Result{ID: "four", Err: errors.New("timeout")})
+	a.Eventually(func() bool { return spooled() == 1 }, time.Second, 5*time.Millisecond)
+
+	// -- Receiver back up: the spool is sent before the new batch
+	lock.Lock()
+	down = false
+	lock.Unlock()
+	sink.Handle(result("five"))
+	sink.Handle(result("six"))
+	flushed(3)
+	a.Equal(0, spooled())
+
+	lock.Lock()
+	a.Equal("three", received[1][0].ID)
+	a.Equal("timeout", received[1][1].Error)
+	a.Equal("five", received[2][0].ID)
+	lock.Unlock()
+
+	// -- Close flushes incomplete batches
+	sink.Handle(result("seven"))
+	a.NoError(sink.Close())
+	flushed(4)
+	sink.Handle(result("eight"))
+
+	_, err = NewWebhookSink(WebhookOptions{URL: "not a url"})
+	a.Error(err)
+}
+
+func TestWebhookSinkStalledReceiver(t *testing.T) {
+	a := assert.New(t)
+
+	for _, spool := range []string{"", filepath.Join(t.TempDir(), "spool")} {
+		var (
+			lock     sync.Mutex
+			received int
+		)
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+
+			var batch []*Summary
+			json.NewDecoder(r.Body).Decode(&batch)
+			lock.Lock()
+			received += len(batch)
+			lock.Unlock()
+		}))
+
+		sink, err := NewWebhookSink(WebhookOptions{
+			URL:           server.URL,
+			FlushInterval: time.Hour,
+			SpoolDir:      spool,
+		})
+		a.NoError(err)
+
+		// -- Handle does not wait for the receiver
+		handled := make(chan struct{})
+		go func() {
+			defer close(handled)
+			for i := 0; i < 20; i++ {
+				sink.Handle(&Result{ID: "stalled"})
+			}
+		}()
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			a.FailNow("handle blocked on a stalled receiver")
+		}
+
+		if len(spool) > 0 {
+			files, err := ioutil.ReadDir(spool)
+			a.NoError(err)
+			a.NotEmpty(files)
+		}
+
+		// -- Close can still flush once the receiver is back
+		close(release)
+		a.NoError(sink.Close())
+		server.Close()
+
+		lock.Lock()
+		if len(spool) == 0 {
+			a.NotZero(sink.Dropped())
+			a.Equal(20, received+int(sink.Dropped()))
+		} else {
+			a.Zero(sink.Dropped())
+			a.Equal(20, received)
+		}
+		lock.Unlock()
+	}
+}
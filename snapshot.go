@@ -0,0 +1,199 @@
+package websitepoller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// DiffText produces unified text diffs between snapshots
+	DiffText string = "text"
+	// DiffJSON produces structural diffs between JSON snapshots
+	DiffJSON string = "json"
+)
+
+// Snapshot is a response body archived on disk
+type Snapshot struct {
+	// Hash of the body, in hexadecimal format. This identifies the snapshot
+	// in the archive.
+	Hash string
+	// Path of the compressed snapshot on disk
+	Path string
+	// Previous is the hash of the previous distinct snapshot, or empty if
+	// this is the first one.
+	Previous string
+	// New specifies whether the body is different from the previous
+	// snapshot
+	New bool
+	// Diff between the previous snapshot and this one, in the format
+	// specified by the snapshot options. This is empty if the body did not
+	// change or diffs are disabled.
+	Diff string
+}
+
+type snapshotArchive struct {
+	id     string
+	dir    string
+	diff   string
+	last   string
+	loaded bool
+	lock   sync.Mutex
+}
+
+func parseSnapshotOptions(id string, opts *SnapshotOptions) (*snapshotArchive, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	if len(opts.Dir) == 0 {
+		return nil, fmt.Errorf("%w: no directory provided", ErrInvalidSnapshotOptions)
+	}
+
+	switch d := strings.ToLower(opts.Diff); d {
+	case "", DiffText, DiffJSON:
+		return &snapshotArchive{id: id, dir: opts.Dir, diff: d}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported diff format %s", ErrInvalidSnapshotOptions, opts.Diff)
+	}
+}
+
+func snapshotPath(dir, hash string) string {
+	return filepath.Join(dir, hash[:2], hash+".gz")
+}
+
+// LoadSnapshot returns the decompressed body of the snapshot with the given
+// hash from the archive in dir.
+func LoadSnapshot(dir, hash string) ([]byte, error) {
+	if len(hash) < 2 {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(snapshotPath(dir, hash))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return ioutil.ReadAll(zr)
+}
+
+// latestPath is the file containing the hash of the latest snapshot of
+// this poller, so that diffs work across restarts.
+func (a *snapshotArchive) latestPath() string {
+	return filepath.Join(a.dir, "latest", url.PathEscape(a.id))
+}
+
+// save archives body, if not already archived, and diffs it against the
+// previous snapshot.
+func (a *snapshotArchive) save(body []byte) (*Snapshot, error) {
+	sum := sha256.Sum256(body)
+	snap := &Snapshot{Hash: hex.EncodeToString(sum[:])}
+	snap.Path = snapshotPath(a.dir, snap.Hash)
+
+	// -- Content-addressed: if it already exists, it is the same body
+	if _, err := os.Stat(snap.Path); os.IsNotExist(err) {
+		if err := writeSnapshot(snap.Path, body); err != nil {
+			return nil, err
+		}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.loaded {
+		latest, err := ioutil.ReadFile(a.latestPath())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		a.last, a.loaded = strings.TrimSpace(string(latest)), true
+	}
+
+	if a.last == snap.Hash {
+		return snap, nil
+	}
+
+	snap.Previous, snap.New = a.last, true
+	if len(snap.Previous) > 0 && len(a.diff) > 0 {
+		// -- Not being able to diff, i.e. because the previous snapshot
+		// was deleted, must not prevent archiving this one
+		diff, err := a.diffWith(snap.Previous, snap.Hash, body)
+		if err != nil {
+			log.Warn().Str("id", a.id).Err(err).Msg("could not diff with previous snapshot")
+		}
+		snap.Diff = diff
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.latestPath()), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(a.latestPath(), []byte(snap.Hash), 0644); err != nil {
+		return nil, err
+	}
+	a.last = snap.Hash
+
+	return snap, nil
+}
+
+func (a *snapshotArchive) diffWith(prevHash, hash string, body []byte) (string, error) {
+	prev, err := LoadSnapshot(a.dir, prevHash)
+	if err != nil {
+		return "", err
+	}
+
+	if a.diff == DiffJSON {
+		diff, err := jsonDiff(prev, body)
+		if err == nil {
+			return diff, nil
+		}
+		log.Warn().Str("id", a.id).Err(err).Msg("could not produce json diff, falling back to text...")
+	}
+
+	return unifiedDiff(prevHash, hash, prev, body), nil
+}
+
+func writeSnapshot(path string, body []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// -- Several pollers may archive the same body at the same time
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,100 @@
+package websitepoller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := assert.New(t)
+
+	a.Empty(unifiedDiff("a", "b", []byte("one\ntwo\n"), []byte("one\ntwo\n")))
+
+	oldText := []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n")
+	newText := []byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n15\n16\n")
+	a.Equal(`--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -11,5 +11,5 @@
+ 11
+ 12
+ 13
+-14
+ 15
++16
+`, unifiedDiff("a", "b", oldText, newText))
+
+	a.Equal(`--- a
++++ b
+@@ -0,0 +1,2 @@
++one
++two
+`, unifiedDiff("a", "b", nil, []byte("one\ntwo")))
+}
+
+func TestJSONDiff(t *testing.T) {
+	a := assert.New(t)
+
+	diff, err := jsonDiff(
+		[]byte(`{"items": [{"price": 10}, {"price": 20}], "discount": true, "name": "shop"}`),
+		[]byte(`{"items": [{"price": 12}], "currency": "EUR", "name": "shop"}`),
+	)
+	a.NoError(err)
+	a.Equal(`+ $.currency: "EUR"
+- $.discount: true
+~ $.items[0].price: 10 -> 12
+- $.items[1]: {"price":20}
+`, diff)
+
+	diff, err = jsonDiff([]byte(`{"a": 1}`), []byte(`{"a": 1}`))
+	a.NoError(err)
+	a.Empty(diff)
+
+	_, err = jsonDiff([]byte(`<html>`), []byte(`{}`))
+	a.Error(err)
+}
+
+func TestSnapshotArchive(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	archive, err := parseSnapshotOptions("poller", &SnapshotOptions{Dir: dir, Diff: DiffText})
+	a.NoError(err)
+
+	first, err := archive.save([]byte("one\n"))
+	a.NoError(err)
+	a.True(first.New)
+	a.Empty(first.Previous)
+	a.Empty(first.Diff)
+
+	same, err := archive.save([]byte("one\n"))
+	a.NoError(err)
+	a.False(same.New)
+	a.Equal(first.Hash, same.Hash)
+
+	// -- A new archive must pick up where the previous one stopped
+	archive, err = parseSnapshotOptions("poller", &SnapshotOptions{Dir: dir, Diff: DiffText})
+	a.NoError(err)
+	second, err := archive.save([]byte("two\n"))
+	a.NoError(err)
+	a.True(second.New)
+	a.Equal(first.Hash, second.Previous)
+	a.Contains(second.Diff, "-one\n+two\n")
+
+	body, err := LoadSnapshot(dir, first.Hash)
+	a.NoError(err)
+	a.Equal([]byte("one\n"), body)
+
+	_, err = parseSnapshotOptions("poller", &SnapshotOptions{Dir: dir, Diff: "xml"})
+	a.Error(err)
+	_, err = parseSnapshotOptions("poller", &SnapshotOptions{})
+	a.Error(err)
+}
@@ -0,0 +1,121 @@
+package websitepoller
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Status contains the current state of a poller
+type Status struct {
+	// ID of the poller
+	ID string `json:"id"`
+	// Paused specifies whether scheduled polls are paused
+	Paused bool `json:"paused"`
+	// NextPoll is the time of the next scheduled poll, or nil if the
+	// poller is not running
+	NextPoll *time.Time `json:"nextPoll,omitempty"`
+	// Stats contains the counters of the polls performed so far
+	Stats Stats `json:"stats"`
+	// Recent contains the summaries of the most recent polls, most recent
+	// first
+	Recent []*Summary `json:"recent"`
+}
+
+// Stats contains the counters of the polls performed by a poller
+type Stats struct {
+	// Polls is the number of polls performed
+	Polls uint64 `json:"polls"`
+	// Failures is the number of polls that failed, including the ones whose
+	// body could not be read or was truncated, or whose response has an
+	// error status, i.e. 404 or 503: see Classify
+	Failures uint64 `json:"failures"`
+	// ConsecutiveFailures is the number of the last polls that failed in a
+	// row
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// Panics is the number of polls that panicked, i.e. in the handler
+	Panics uint64 `json:"panics"`
+	// ConsecutivePanics is the number of the last polls that panicked in a
+	// row
+	ConsecutivePanics int `json:"consecutivePanics"`
+	// Disabled specifies whether the poller was disabled because of too
+	// many consecutive panics
+	Disabled bool `json:"disabled"`
+	// ReusedConnections is the number of traced polls that reused an idle
+	// connection
+	ReusedConnections uint64 `json:"reusedConnections"`
+	// DroppedResults is the number of results that were not sent on the
+	// results channel because it was full
+	DroppedResults uint64 `json:"droppedResults"`
+	// LastTimings contains the timings of the last successful traced poll,
+	// or nil if tracing is not enabled
+	LastTimings *Timings `json:"lastTimings,omitempty"`
+}
+
+type pollerStats struct {
+	polls             uint64
+	failures          uint64
+	consecFailures    int32
+	panics            uint64
+	consecutivePanics int32
+	disabled          int32
+	reusedConns       uint64
+	droppedResults    uint64
+	lastTimings       atomic.Value
+}
+
+func (s *pollerStats) record(err error) {
+	atomic.AddUint64(&s.polls, 1)
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		atomic.AddInt32(&s.consecFailures, 1)
+		return
+	}
+	atomic.StoreInt32(&s.consecFailures, 0)
+}
+
+// recordPanic counts a panic and returns the number of consecutive ones
+func (s *pollerStats) recordPanic() int {
+	atomic.AddUint64(&s.panics, 1)
+	return int(atomic.AddInt32(&s.consecutivePanics, 1))
+}
+
+func (s *pollerStats) resetPanics() {
+	atomic.StoreInt32(&s.consecutivePanics, 0)
+}
+
+// disable marks the poller as disabled and returns true if it was not
+// already
+func (s *pollerStats) disable() bool {
+	return atomic.CompareAndSwapInt32(&s.disabled, 0, 1)
+}
+
+func (s *pollerStats) isDisabled() bool {
+	return atomic.LoadInt32(&s.disabled) == 1
+}
+
+func (s *pollerStats) recordTimings(t *Timings) {
+	if t.ConnReused {
+		atomic.AddUint64(&s.reusedConns, 1)
+	}
+	s.lastTimings.Store(t)
+}
+
+func (s *pollerStats) recordDroppedResult() {
+	atomic.AddUint64(&s.droppedResults, 1)
+}
+
+func (s *pollerStats) snapshot() Stats {
+	last, _ := s.lastTimings.Load().(*Timings)
+
+	return Stats{
+		Polls:               atomic.LoadUint64(&s.polls),
+		Failures:            atomic.LoadUint64(&s.failures),
+		ConsecutiveFailures: int(atomic.LoadInt32(&s.consecFailures)),
+		Panics:              atomic.LoadUint64(&s.panics),
+		ConsecutivePanics:   int(atomic.LoadInt32(&s.consecutivePanics)),
+		Disabled:            s.isDisabled(),
+		ReusedConnections:   atomic.LoadUint64(&s.reusedConns),
+		DroppedResults:      atomic.LoadUint64(&s.droppedResults),
+		LastTimings:         last,
+	}
+}
@@ -0,0 +1,188 @@
+package websitepoller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type step struct {
+	name     string
+	request  *requestTemplate
+	order    map[string]int
+	captures []*capture
+}
+
+type capture struct {
+	name      string
+	header    string
+	cookie    string
+	extractor *extractor
+}
+
+// parseSteps validates the steps and returns them along with the names of
+// the variables they define. All problems are returned in a
+// *ValidationError, under the index of their step.
+func parseSteps(steps []Step) ([]*step, map[string]bool, error) {
+	v := &validator{}
+	parsed := make([]*step, 0, len(steps))
+	names := map[string]bool{}
+	defined := map[string]bool{}
+
+	for i, s := range steps {
+		path := indexPath(i)
+		if len(s.Name) == 0 || names[s.Name] {
+			v.check(joinPath(path, "name"), fmt.Errorf("%w: missing or duplicate name %q", ErrInvalidStep, s.Name))
+		}
+		names[s.Name] = true
+
+		method, err := parseHTTPMethod(s.Method)
+		v.check(joinPath(path, "method"), err)
+		validURL := v.check(joinPath(path, "url"), parseTemplatedURL(s.URL))
+
+		st := &step{
+			name:    s.Name,
+			request: newRequestTemplate(method, s.URL, s.Headers, s.Body),
+			order:   headerOrder(s.PreserveHeaderOrder, s.Headers),
+		}
+
+		// -- A step can only use values captured by the previous ones
+		if validURL {
+			v.check(joinPath(path, "url"), checkTemplateVars(newRequestTemplate(method, s.URL, nil, ""), defined))
+		}
+		v.check(joinPath(path, "headers"), checkTemplateVars(newRequestTemplate(method, "", s.Headers, ""), defined))
+		v.check(joinPath(path, "body"), checkTemplateVars(newRequestTemplate(method, "", nil, s.Body), defined))
+
+		captured := map[string]bool{}
+		for j, c := range s.Captures {
+			capturePath := joinPath(path, "captures"+indexPath(j))
+			parsedCapture, err := parseCapture(c)
+			if !v.check(capturePath, err) {
+				continue
+			}
+			if defined[c.Name] || captured[c.Name] {
+				v.check(joinPath(capturePath, "name"), fmt.Errorf("%w: duplicate capture %s", ErrInvalidStep, c.Name))
+				continue
+			}
+
+			captured[c.Name] = true
+			st.captures = append(st.captures, parsedCapture)
+		}
+
+		// -- Defined later, so a step cannot use its own captures
+		for name := range captured {
+			defined[name] = true
+		}
+
+		parsed = append(parsed, st)
+	}
+
+	if err := v.err(); err != nil {
+		return nil, nil, err
+	}
+
+	return parsed, defined, nil
+}
+
+func parseCapture(c Capture) (*capture, error) {
+	v := &validator{}
+	if len(c.Name) == 0 {
+		v.check("name", fmt.Errorf("%w: capture without name", ErrInvalidStep))
+	}
+
+	parsed := &capture{name: c.Name, header: c.Header, cookie: c.Cookie}
+	hasExpr := len(c.CSS) > 0 || len(c.XPath) > 0 || len(c.JSONPath) > 0 || len(c.Regex) > 0
+
+	switch {
+	case len(c.Header) > 0 && len(c.Cookie) == 0 && !hasExpr:
+	case len(c.Cookie) > 0 && len(c.Header) == 0 && !hasExpr:
+	case len(c.Header) == 0 && len(c.Cookie) == 0:
+		ext, err := parseExtractor(c.Extractor)
+		v.check("", err)
+		parsed.extractor = ext
+	default:
+		v.check("", fmt.Errorf("%w: capture %s must define exactly one source", ErrInvalidStep, c.Name))
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+func checkTemplateVars(t *requestTemplate, defined map[string]bool) error {
+	names, err := t.vars()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if defined[name] {
+			continue
+		}
+		if _, err := parseBuiltin(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runSteps performs all the steps in order and adds the captured values to
+// vars
+func (p *pagePoller) runSteps(ctx context.Context, client *http.Client, userAgent string, vars map[string]string, lookup func(string) (string, error)) error {
+	for _, s := range p.steps {
+		req, err := s.request.build(withHeaderOrder(ctx, s.order), lookup)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", s.name, err)
+		}
+		if len(userAgent) > 0 {
+			req.Header.Set(userAgentHeaderKey, userAgent)
+		}
+		injectTraceContext(ctx, req)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", s.name, err)
+		}
+		if _, err := decodeResponse(resp); err != nil {
+			return fmt.Errorf("step %s: %w", s.name, err)
+		}
+		body, _, err := readBody(resp.Body, p.maxBodySize)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", s.name, err)
+		}
+
+		for _, c := range s.captures {
+			val, ok := c.value(p.id, resp, body)
+			if !ok {
+				return fmt.Errorf("step %s: %w: %s", s.name, ErrCaptureNotFound, c.name)
+			}
+			vars[c.name] = val
+		}
+	}
+
+	return nil
+}
+
+func (c *capture) value(id string, resp *http.Response, body []byte) (string, bool) {
+	switch {
+	case len(c.header) > 0:
+		vals := resp.Header.Values(c.header)
+		if len(vals) == 0 {
+			return "", false
+		}
+		return vals[0], true
+	case len(c.cookie) > 0:
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == c.cookie {
+				return cookie.Value, true
+			}
+		}
+		return "", false
+	default:
+		val, ok := extractFields(id, []*extractor{c.extractor}, body)[c.name]
+		return val, ok
+	}
+}
@@ -0,0 +1,122 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSteps(t *testing.T) {
+	a := assert.New(t)
+
+	cases := []struct {
+		arg    []Step
+		expErr error
+	}{
+		{
+			arg: []Step{
+				{Name: "one", URL: "https://example.com", Captures: []Capture{{Extractor: Extractor{Name: "token", Regex: "[a-z]+"}}}},
+				{Name: "two", URL: "https://example.com/${token}", Captures: []Capture{{Extractor: Extractor{Name: "session"}, Cookie: "sid"}}},
+			},
+		},
+		{
+			arg:    []Step{{URL: "https://example.com"}},
+			expErr: ErrInvalidStep,
+		},
+		{
+			arg:    []Step{{Name: "one", URL: "example.com"}},
+			expErr: ErrURLNoScheme,
+		},
+		{
+			arg:    []Step{{Name: "one", URL: "https://example.com/${token}", Captures: []Capture{{Extractor: Extractor{Name: "token"}, Header: "X-Token"}}}},
+			expErr: ErrUnknownVariable,
+		},
+		{
+			arg:    []Step{{Name: "one", URL: "https://example.com", Captures: []Capture{{Extractor: Extractor{Name: "token", Regex: "[a-z]+"}, Header: "X-Token"}}}},
+			expErr: ErrInvalidStep,
+		},
+		{
+			arg: []Step{{Name: "one", URL: "https://example.com", Captures: []Capture{
+				{Extractor: Extractor{Name: "token"}, Header: "X-Token"},
+				{Extractor: Extractor{Name: "token"}, Cookie: "token"},
+			}}},
+			expErr: ErrInvalidStep,
+		},
+	}
+
+	for i, currCase := range cases {
+		_, _, err := parseSteps(currCase.arg)
+
+		if currCase.expErr != nil {
+			a.True(errors.Is(err, currCase.expErr), fmt.Sprintf("case %d failed: %v", i, err))
+		} else {
+			a.NoError(err, fmt.Sprintf("case %d failed", i))
+		}
+	}
+}
+
+func TestSteps(t *testing.T) {
+	a := assert.New(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "session-1"})
+		w.Header().Set("X-Csrf", "csrf-1")
+		fmt.Fprint(w, `{"token": "token-1"}`)
+	})
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		sid, err := r.Cookie("sid")
+		if err != nil || sid.Value != "session-1" || r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.URL.Query().Get("csrf"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	page := &Page{
+		URL:     server.URL + "/items?csrf=${csrf}",
+		Headers: Headers{{Key: "Authorization", Values: []string{"Bearer ${token}"}}},
+		Steps: []Step{
+			{
+				Name: "login",
+				URL:  server.URL + "/login",
+				Captures: []Capture{
+					{Extractor: Extractor{Name: "token", JSONPath: "$.token"}},
+					{Extractor: Extractor{Name: "csrf"}, Header: "X-Csrf"},
+				},
+			},
+		},
+		Extractors: []Extractor{{Name: "body", Regex: ".+"}},
+	}
+
+	p, err := New(page)
+	a.NoError(err)
+
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+
+	a.NoError(res.Err)
+	a.Equal(http.StatusOK, res.Response.StatusCode)
+	a.Equal("csrf-1", res.Fields["body"])
+
+	// -- A capture that cannot be found fails the poll
+	page.Steps[0].Captures[1].Header = "X-Missing"
+	p, err = New(page)
+	a.NoError(err)
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+	a.True(errors.Is(res.Err, ErrCaptureNotFound))
+
+	// -- The page can only use variables defined by steps or built-ins
+	page.Headers = append(page.Headers, Header{Key: "X-Other", Values: []string{"${other}"}})
+	_, err = New(page)
+	a.True(errors.Is(err, ErrUnknownVariable))
+}
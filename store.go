@@ -0,0 +1,123 @@
+package websitepoller
+
+import (
+	"sort"
+	"time"
+)
+
+// Store persists the history of polls, so that the state of a poller, i.e.
+// the user agent rotation and change detection, survives restarts.
+// Implementations must be safe for concurrent use, as the same store can be
+// shared among several pollers.
+type Store interface {
+	// Save records the given poll
+	Save(r *Record) error
+	// Last returns the most recent record of the poller with the given ID,
+	// or nil if the poller has no records.
+	Last(id string) (*Record, error)
+	// List returns the records of the poller with the given ID, most recent
+	// first. If limit is greater than zero, at most limit records are
+	// returned.
+	List(id string, limit int) ([]*Record, error)
+	// Close releases the resources used by the store
+	Close() error
+}
+
+// Record contains the metadata of a poll
+type Record struct {
+	// ID of the poller that performed the poll
+	ID string `json:"id"`
+	// Time when the poll was performed
+	Time time.Time `json:"time"`
+	// Duration of the request
+	Duration time.Duration `json:"duration"`
+	// StatusCode of the response, or 0 if the request failed
+	StatusCode int `json:"statusCode,omitempty"`
+	// Error occurred while polling, if any
+	Error string `json:"error,omitempty"`
+	// UserAgentIndex is the index of the user agent used for this poll, or
+	// -1 if no user agent from the list was used
+	UserAgentIndex int `json:"userAgentIndex"`
+	// ETag header of the response
+	ETag string `json:"etag,omitempty"`
+	// LastModified header of the response
+	LastModified string `json:"lastModified,omitempty"`
+	// Hash is the hash used for change detection
+	Hash string `json:"hash,omitempty"`
+	// Changed specifies whether a change was detected on this poll
+	Changed bool `json:"changed,omitempty"`
+	// Fields contains the extracted values
+	Fields map[string]string `json:"fields,omitempty"`
+	// Body of the response. This is only saved if the store was created
+	// with KeepBodies.
+	Body []byte `json:"body,omitempty"`
+}
+
+// StoreOptions contains options about the retention of records
+type StoreOptions struct {
+	// MaxRecords is the maximum number of records kept for each poller.
+	// Older records are deleted first. Zero means no limit.
+	MaxRecords int
+	// MaxAge is the maximum age of records. Older records are deleted
+	// when a new one is saved. Zero means no limit.
+	MaxAge time.Duration
+	// KeepBodies specifies whether response bodies should be saved
+	KeepBodies bool
+}
+
+func newRecord(res *Result, uaIndex int) *Record {
+	r := &Record{
+		ID:             res.ID,
+		Time:           res.Time,
+		Duration:       res.Duration,
+		UserAgentIndex: uaIndex,
+		Hash:           res.Hash,
+		Changed:        res.Changed,
+		Fields:         res.Fields,
+		Body:           res.Body,
+	}
+
+	if res.Err != nil {
+		r.Error = res.Err.Error()
+	}
+
+	if res.Response != nil {
+		r.StatusCode = res.Response.StatusCode
+		r.ETag = res.Response.Header.Get("ETag")
+		r.LastModified = res.Response.Header.Get("Last-Modified")
+	}
+
+	return r
+}
+
+// applyRetention returns the records that should be kept, sorted from the
+// most recent to the oldest.
+func applyRetention(records []*Record, opts StoreOptions, now time.Time) []*Record {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+
+	if opts.MaxRecords > 0 && len(records) > opts.MaxRecords {
+		records = records[:opts.MaxRecords]
+	}
+
+	if opts.MaxAge > 0 {
+		limit := now.Add(-opts.MaxAge)
+		for i, r := range records {
+			if r.Time.Before(limit) {
+				records = records[:i]
+				break
+			}
+		}
+	}
+
+	return records
+}
+
+func limitRecords(records []*Record, limit int) []*Record {
+	if limit > 0 && len(records) > limit {
+		return records[:limit]
+	}
+
+	return records
+}
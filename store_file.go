@@ -0,0 +1,129 @@
+package websitepoller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type fileStore struct {
+	dir     string
+	opts    StoreOptions
+	records map[string][]*Record
+	lock    sync.Mutex
+}
+
+// NewFileStore returns a store that keeps records on disk, as JSON files
+// inside dir: one file for each poller. The directory is created if it does
+// not exist. As each file is rewritten on every poll, you should provide
+// retention limits with opts.
+func NewFileStore(dir string, opts StoreOptions) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{
+		dir:     dir,
+		opts:    opts,
+		records: map[string][]*Record{},
+	}, nil
+}
+
+func (s *fileStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".json")
+}
+
+// load returns the records of the given poller, reading them from disk the
+// first time. It must be called with the lock held.
+func (s *fileStore) load(id string) ([]*Record, error) {
+	if records, exists := s.records[id]; exists {
+		return records, nil
+	}
+
+	records := []*Record{}
+	data, err := ioutil.ReadFile(s.path(id))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, err
+		}
+	}
+
+	s.records[id] = records
+	return records, nil
+}
+
+// Save records the given poll
+func (s *fileStore) Save(r *Record) error {
+	if !s.opts.KeepBodies {
+		copied := *r
+		copied.Body = nil
+		r = &copied
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records, err := s.load(r.ID)
+	if err != nil {
+		return err
+	}
+	records = applyRetention(append([]*Record{r}, records...), s.opts, time.Now())
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	// -- Write to a temporary file first, so that a crash does not leave a
+	// truncated file behind
+	tmp := s.path(r.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(r.ID)); err != nil {
+		return err
+	}
+
+	s.records[r.ID] = records
+	return nil
+}
+
+// Last returns the most recent record of the poller with the given ID
+func (s *fileStore) Last(id string) (*Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records, err := s.load(id)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	return records[0], nil
+}
+
+// List returns the records of the poller with the given ID, most recent
+// first
+func (s *fileStore) List(id string, limit int) ([]*Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*Record{}, limitRecords(records, limit)...), nil
+}
+
+// Close does nothing for the file store, as files are written on each save
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package websitepoller
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	opts    StoreOptions
+	records map[string][]*Record
+	lock    sync.RWMutex
+}
+
+// NewMemoryStore returns a store that keeps records in memory. Records are
+// lost when the program exits, so this is mostly useful for tests and to
+// inspect recent polls.
+func NewMemoryStore(opts StoreOptions) Store {
+	return &memoryStore{
+		opts:    opts,
+		records: map[string][]*Record{},
+	}
+}
+
+// Save records the given poll
+func (s *memoryStore) Save(r *Record) error {
+	if !s.opts.KeepBodies {
+		copied := *r
+		copied.Body = nil
+		r = &copied
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := append([]*Record{r}, s.records[r.ID]...)
+	s.records[r.ID] = applyRetention(records, s.opts, time.Now())
+	return nil
+}
+
+// Last returns the most recent record of the poller with the given ID
+func (s *memoryStore) Last(id string) (*Record, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if records := s.records[id]; len(records) > 0 {
+		return records[0], nil
+	}
+
+	return nil, nil
+}
+
+// List returns the records of the poller with the given ID, most recent
+// first
+func (s *memoryStore) List(id string, limit int) ([]*Record, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	records := limitRecords(s.records[id], limit)
+	return append([]*Record{}, records...), nil
+}
+
+// Close does nothing for the memory store
+func (s *memoryStore) Close() error {
+	return nil
+}
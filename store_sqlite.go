@@ -0,0 +1,147 @@
+package websitepoller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS poll_records (
+	poller_id      TEXT    NOT NULL,
+	time           INTEGER NOT NULL,
+	duration       INTEGER NOT NULL,
+	status_code    INTEGER NOT NULL,
+	error          TEXT    NOT NULL,
+	ua_index       INTEGER NOT NULL,
+	etag           TEXT    NOT NULL,
+	last_modified  TEXT    NOT NULL,
+	hash           TEXT    NOT NULL,
+	changed        INTEGER NOT NULL,
+	fields         TEXT    NOT NULL,
+	body           BLOB
+);
+CREATE INDEX IF NOT EXISTS poll_records_poller_time ON poll_records (poller_id, time DESC);
+`
+
+type sqliteStore struct {
+	db   *sql.DB
+	opts StoreOptions
+}
+
+// NewSQLiteStore returns a store that keeps records in a SQLite database.
+// This package does not import any SQLite driver: you must import the one
+// of your choice and open db yourself, e.g. with modernc.org/sqlite:
+//
+//	import _ "modernc.org/sqlite"
+//	db, err := sql.Open("sqlite", "polls.db")
+//
+// The table is created if it does not exist. Closing the store closes db.
+func NewSQLiteStore(db *sql.DB, opts StoreOptions) (Store, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+
+	return &sqliteStore{db: db, opts: opts}, nil
+}
+
+// Save records the given poll
+func (s *sqliteStore) Save(r *Record) error {
+	fields, err := json.Marshal(r.Fields)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if s.opts.KeepBodies {
+		body = r.Body
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO poll_records
+		(poller_id, time, duration, status_code, error, ua_index, etag, last_modified, hash, changed, fields, body)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.Time.UnixNano(), int64(r.Duration), r.StatusCode, r.Error, r.UserAgentIndex, r.ETag, r.LastModified, r.Hash, r.Changed, string(fields), body)
+	if err != nil {
+		return err
+	}
+
+	// -- Retention
+	if s.opts.MaxRecords > 0 {
+		_, err = tx.Exec(`DELETE FROM poll_records WHERE poller_id = ? AND time < (
+			SELECT MIN(time) FROM (
+				SELECT time FROM poll_records WHERE poller_id = ? ORDER BY time DESC LIMIT ?
+			)
+		)`, r.ID, r.ID, s.opts.MaxRecords)
+		if err != nil {
+			return err
+		}
+	}
+	if s.opts.MaxAge > 0 {
+		limit := time.Now().Add(-s.opts.MaxAge).UnixNano()
+		if _, err = tx.Exec(`DELETE FROM poll_records WHERE poller_id = ? AND time < ?`, r.ID, limit); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Last returns the most recent record of the poller with the given ID
+func (s *sqliteStore) Last(id string) (*Record, error) {
+	records, err := s.List(id, 1)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	return records[0], nil
+}
+
+// List returns the records of the poller with the given ID, most recent
+// first
+func (s *sqliteStore) List(id string, limit int) ([]*Record, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.Query(`SELECT
+		poller_id, time, duration, status_code, error, ua_index, etag, last_modified, hash, changed, fields, body
+		FROM poll_records WHERE poller_id = ? ORDER BY time DESC LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*Record{}
+	for rows.Next() {
+		var (
+			r        Record
+			at       int64
+			duration int64
+			fields   string
+		)
+
+		err := rows.Scan(&r.ID, &at, &duration, &r.StatusCode, &r.Error, &r.UserAgentIndex, &r.ETag, &r.LastModified, &r.Hash, &r.Changed, &fields, &r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(fields), &r.Fields); err != nil {
+			return nil, err
+		}
+
+		r.Time, r.Duration = time.Unix(0, at), time.Duration(duration)
+		records = append(records, &r)
+	}
+
+	return records, rows.Err()
+}
+
+// Close closes the underlying database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,100 @@
+package websitepoller
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestStores(t *testing.T) {
+	opts := StoreOptions{MaxRecords: 3}
+
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "store"), opts)
+	assert.NoError(t, err)
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "polls.db"))
+	assert.NoError(t, err)
+	sqliteStore, err := NewSQLiteStore(db, opts)
+	assert.NoError(t, err)
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(opts),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, s)
+		})
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	a := assert.New(t)
+	defer s.Close()
+
+	last, err := s.Last("poller/one")
+	a.NoError(err)
+	a.Nil(last)
+
+	now := time.Now().Truncate(time.Millisecond)
+	for i := 0; i < 5; i++ {
+		err := s.Save(&Record{
+			ID:             "poller/one",
+			Time:           now.Add(time.Duration(i) * time.Second),
+			StatusCode:     200,
+			UserAgentIndex: i,
+			Hash:           fmt.Sprintf("hash-%d", i),
+			Fields:         map[string]string{"price": fmt.Sprint(i)},
+			Body:           []byte("body"),
+		})
+		a.NoError(err)
+	}
+	a.NoError(s.Save(&Record{ID: "two", Time: now, Error: "timeout"}))
+
+	last, err = s.Last("poller/one")
+	a.NoError(err)
+	a.Equal(4, last.UserAgentIndex)
+	a.Equal("hash-4", last.Hash)
+	a.Equal(map[string]string{"price": "4"}, last.Fields)
+	a.True(now.Add(4 * time.Second).Equal(last.Time))
+	a.Empty(last.Body)
+
+	records, err := s.List("poller/one", 0)
+	a.NoError(err)
+	a.Len(records, 3)
+	a.Equal("hash-2", records[2].Hash)
+
+	records, err = s.List("poller/one", 2)
+	a.NoError(err)
+	a.Len(records, 2)
+
+	last, err = s.Last("two")
+	a.NoError(err)
+	a.Equal("timeout", last.Error)
+}
+
+func TestApplyRetention(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Now()
+	records := []*Record{
+		{Time: now.Add(-3 * time.Hour)},
+		{Time: now},
+		{Time: now.Add(-time.Hour)},
+	}
+
+	kept := applyRetention(records, StoreOptions{MaxAge: 2 * time.Hour}, now)
+	a.Len(kept, 2)
+	a.Equal(now, kept[0].Time)
+
+	kept = applyRetention(records, StoreOptions{MaxRecords: 1}, now)
+	a.Len(kept, 1)
+	a.Equal(now, kept[0].Time)
+}
@@ -0,0 +1,102 @@
+package websitepoller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName string = "github.com/SunSince90/website-poller"
+	pollSpanName        string = "poll"
+	pollerIDKey                = attribute.Key("poller.id")
+)
+
+// propagator injects the W3C trace context in the requests to the pages,
+// regardless of the global propagator, which does nothing by default.
+var propagator = propagation.TraceContext{}
+
+// defaultTracer returns the tracer of the global provider. Unless the
+// program sets one with otel.SetTracerProvider, spans are not recorded.
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(instrumentationName)
+}
+
+// startPollSpan starts the span of a poll
+func (p *pagePoller) startPollSpan(ctx context.Context, userAgent string) (context.Context, trace.Span) {
+	p.lock.Lock()
+	tracer := p.tracer
+	p.lock.Unlock()
+
+	attrs := []attribute.KeyValue{
+		pollerIDKey.String(p.id),
+		semconv.HTTPRequestMethodKey.String(p.request.method),
+	}
+	if len(userAgent) > 0 {
+		attrs = append(attrs, semconv.UserAgentOriginal(userAgent))
+	}
+
+	return tracer.Start(ctx, pollSpanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endPollSpan records the outcome of the poll in the span and ends it
+func endPollSpan(span trace.Span, res *Result) {
+	defer span.End()
+
+	if res.Err != nil {
+		span.RecordError(res.Err)
+		span.SetStatus(codes.Error, res.Err.Error())
+		return
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.Response.StatusCode))
+	if res.Response.StatusCode >= 400 {
+		span.SetStatus(codes.Error, res.Response.Status)
+	}
+}
+
+// traceRequest injects the trace context of ctx in the headers of the
+// request to the page, records its URL, with the variables evaluated, and
+// counts how many times it is sent again, i.e. because of redirects, in the
+// span.
+func traceRequest(ctx context.Context, req *http.Request) *http.Request {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() && !span.SpanContext().IsValid() {
+		return req
+	}
+
+	injectTraceContext(ctx, req)
+	span.SetAttributes(semconv.URLFull(req.URL.Redacted()))
+
+	var sent int32
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			if resend := atomic.AddInt32(&sent, 1) - 1; resend > 0 {
+				span.SetAttributes(semconv.HTTPRequestResendCount(int(resend)))
+			}
+		},
+	}))
+}
+
+// injectTraceContext injects the trace context of ctx, if any, in the
+// headers of the request, i.e. of a step
+func injectTraceContext(ctx context.Context, req *http.Request) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// SetTracerProvider sets the provider of the tracer used to create a span
+// for each poll. By default, the global provider is used.
+func (p *pagePoller) SetTracerProvider(tp trace.TracerProvider) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.tracer = tp.Tracer(instrumentationName)
+}
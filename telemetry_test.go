@@ -0,0 +1,126 @@
+package websitepoller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPollSpans(t *testing.T) {
+	a := assert.New(t)
+
+	traceparents := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("Traceparent"))
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	poll := func(path string) sdktrace.ReadOnlySpan {
+		id := "traced"
+		p, err := New(&Page{
+			ID:               &id,
+			URL:              server.URL + path,
+			FollowRedirect:   true,
+			UserAgentOptions: &UserAgentOptions{UserAgents: []string{"poller-test"}},
+		})
+		a.NoError(err)
+		p.SetTracerProvider(provider)
+		p.(*pagePoller).poll(context.Background())
+
+		spans := exporter.GetSpans().Snapshots()
+		exporter.Reset()
+		a.Len(spans, 1)
+		return spans[0]
+	}
+	attrs := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	span := poll("/moved")
+	a.Equal("poll", span.Name())
+	a.Equal(trace.SpanKindClient, span.SpanKind())
+	a.Equal(codes.Unset, span.Status().Code)
+	values := attrs(span)
+	a.Equal("traced", values["poller.id"].AsString())
+	a.Equal("GET", values["http.request.method"].AsString())
+	a.Equal(server.URL+"/moved", values["url.full"].AsString())
+	a.Equal("poller-test", values["user_agent.original"].AsString())
+	a.Equal(int64(200), values["http.response.status_code"].AsInt64())
+	a.Equal(int64(1), values["http.request.resend_count"].AsInt64())
+
+	// -- The W3C trace context is sent with the request
+	a.Len(traceparents, 2)
+	a.Contains(traceparents[0], span.SpanContext().TraceID().String())
+	a.Contains(traceparents[0], span.SpanContext().SpanID().String())
+
+	span = poll("/missing")
+	a.Equal(codes.Error, span.Status().Code)
+
+	// -- Nothing is sent with the default no-op provider
+	traceparents = nil
+	p, err := New(&Page{URL: server.URL})
+	a.NoError(err)
+	p.(*pagePoller).poll(context.Background())
+	a.Equal([]string{""}, traceparents)
+}
+
+func TestPollSpanSteps(t *testing.T) {
+	a := assert.New(t)
+
+	traceparents := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents[r.URL.Path] = r.Header.Get("Traceparent")
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	p, err := New(&Page{
+		URL:   server.URL + "/page?n=${counter}",
+		Steps: []Step{{Name: "login", URL: server.URL + "/login"}},
+	})
+	a.NoError(err)
+	p.SetTracerProvider(provider)
+	p.(*pagePoller).poll(context.Background())
+
+	spans := exporter.GetSpans().Snapshots()
+	a.Len(spans, 1)
+
+	// -- The URL is the one requested, not the template
+	url := ""
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "url.full" {
+			url = kv.Value.AsString()
+		}
+	}
+	a.Equal(server.URL+"/page?n=1", url)
+
+	// -- Steps carry the trace context too
+	traceID := spans[0].SpanContext().TraceID().String()
+	a.Contains(traceparents["/login"], traceID)
+	a.Contains(traceparents["/page"], traceID)
+}
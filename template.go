@@ -0,0 +1,193 @@
+package websitepoller
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateVars returns the names of the variables referenced in s with the
+// ${name} syntax. A literal $ can be written as $$.
+func templateVars(s string) ([]string, error) {
+	names := []string{}
+	_, err := expandTemplate(s, func(name string) (string, error) {
+		names = append(names, name)
+		return "", nil
+	})
+
+	return names, err
+}
+
+// expandTemplate replaces all the ${name} variables in s with the value
+// returned by lookup.
+func expandTemplate(s string, lookup func(string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			sb.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("%w: unclosed variable in %q", ErrInvalidTemplate, s)
+			}
+
+			name := strings.TrimSpace(s[i+2 : i+2+end])
+			if len(name) == 0 {
+				return "", fmt.Errorf("%w: empty variable in %q", ErrInvalidTemplate, s)
+			}
+
+			val, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(val)
+			i += end + 2
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// builtinFunc returns the value of a built-in variable. counter is the
+// number of the current poll, starting from 1.
+type builtinFunc func(counter uint64) (string, error)
+
+// parseBuiltin returns the function that evaluates the built-in variable
+// with the given name. Built-ins with arguments use the ${name:args}
+// syntax, i.e. ${date:2006-01-02} or ${randInt:1:100}.
+func parseBuiltin(name string) (builtinFunc, error) {
+	fn, arg := name, ""
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		fn, arg = name[:i], name[i+1:]
+	}
+
+	noArgs := func(f builtinFunc) (builtinFunc, error) {
+		if len(arg) > 0 {
+			return nil, fmt.Errorf("%w: %s does not take arguments", ErrInvalidTemplate, fn)
+		}
+		return f, nil
+	}
+	withArg := func(f builtinFunc) (builtinFunc, error) {
+		if len(arg) == 0 {
+			return nil, fmt.Errorf("%w: %s requires an argument", ErrInvalidTemplate, fn)
+		}
+		return f, nil
+	}
+
+	switch fn {
+	case "unix":
+		return noArgs(func(uint64) (string, error) {
+			return strconv.FormatInt(time.Now().Unix(), 10), nil
+		})
+	case "unixMilli":
+		return noArgs(func(uint64) (string, error) {
+			return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10), nil
+		})
+	case "date":
+		return withArg(func(uint64) (string, error) {
+			return time.Now().Format(arg), nil
+		})
+	case "utcDate":
+		return withArg(func(uint64) (string, error) {
+			return time.Now().UTC().Format(arg), nil
+		})
+	case "uuid":
+		return noArgs(func(uint64) (string, error) {
+			return newUUID()
+		})
+	case "counter":
+		return noArgs(func(counter uint64) (string, error) {
+			return strconv.FormatUint(counter, 10), nil
+		})
+	case "randInt":
+		bounds := strings.Split(arg, ":")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("%w: randInt requires min and max, i.e. ${randInt:1:100}", ErrInvalidTemplate)
+		}
+		min, errMin := strconv.Atoi(bounds[0])
+		max, errMax := strconv.Atoi(bounds[1])
+		if errMin != nil || errMax != nil || max < min {
+			return nil, fmt.Errorf("%w: invalid randInt range %s", ErrInvalidTemplate, arg)
+		}
+		return func(uint64) (string, error) {
+			return strconv.Itoa(min + rand.Intn(max-min+1)), nil
+		}, nil
+	case "env":
+		return withArg(func(uint64) (string, error) {
+			val, exists := os.LookupEnv(arg)
+			if !exists {
+				return "", fmt.Errorf("%w: environment variable %s is not set", ErrUnknownVariable, arg)
+			}
+			return val, nil
+		})
+	case "file":
+		// -- The file is read on each poll, so that secrets can be rotated
+		return withArg(func(uint64) (string, error) {
+			content, err := ioutil.ReadFile(arg)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(content), "\r\n"), nil
+		})
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVariable, name)
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := crand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// pollLookup returns a lookup function for expandTemplate that reads from
+// vars first and then from the built-ins. Values are evaluated once per
+// poll, so the same variable has the same value everywhere in a poll.
+func pollLookup(vars map[string]string, counter uint64) func(string) (string, error) {
+	evaluated := map[string]string{}
+
+	return func(name string) (string, error) {
+		if val, exists := vars[name]; exists {
+			return val, nil
+		}
+		if val, exists := evaluated[name]; exists {
+			return val, nil
+		}
+
+		fn, err := parseBuiltin(name)
+		if err != nil {
+			return "", err
+		}
+		val, err := fn(counter)
+		if err != nil {
+			return "", err
+		}
+
+		evaluated[name] = val
+		return val, nil
+	}
+}
@@ -0,0 +1,86 @@
+package websitepoller
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	a := assert.New(t)
+	lookup := pollLookup(map[string]string{"token": "abc", "page": "2"}, 1)
+
+	cases := []struct {
+		arg    string
+		exp    string
+		expErr error
+	}{
+		{arg: "https://example.com", exp: "https://example.com"},
+		{arg: "Bearer ${token}", exp: "Bearer abc"},
+		{arg: "/list?page=${ page }&t=${token}", exp: "/list?page=2&t=abc"},
+		{arg: "cost: $$5 or $6", exp: "cost: $5 or $6"},
+		{arg: "${token", expErr: ErrInvalidTemplate},
+		{arg: "${}", expErr: ErrInvalidTemplate},
+		{arg: "${missing}", expErr: ErrUnknownVariable},
+	}
+
+	for i, currCase := range cases {
+		res, err := expandTemplate(currCase.arg, lookup)
+
+		if currCase.expErr != nil {
+			a.True(errors.Is(err, currCase.expErr), fmt.Sprintf("case %d failed", i))
+		} else {
+			a.NoError(err, fmt.Sprintf("case %d failed", i))
+			a.Equal(currCase.exp, res, fmt.Sprintf("case %d failed", i))
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	a := assert.New(t)
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	a.NoError(ioutil.WriteFile(secret, []byte("s3cr3t\n"), 0600))
+	os.Setenv("POLLER_TEST_VAR", "from-env")
+	defer os.Unsetenv("POLLER_TEST_VAR")
+
+	lookup := pollLookup(map[string]string{"counter": "shadowed"}, 7)
+	expand := func(s string) string {
+		res, err := expandTemplate(s, lookup)
+		a.NoError(err, s)
+		return res
+	}
+
+	unix, err := strconv.ParseInt(expand("${unix}"), 10, 64)
+	a.NoError(err)
+	a.InDelta(time.Now().Unix(), unix, 2)
+	a.Equal(time.Now().UTC().Format("2006-01-02"), expand("${utcDate:2006-01-02}"))
+	a.Regexp("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", expand("${uuid}"))
+	a.Equal(expand("${uuid}"), expand("${uuid}"), "values must not change within a poll")
+	a.Equal("from-env", expand("${env:POLLER_TEST_VAR}"))
+	a.Equal("s3cr3t", expand("${file:"+secret+"}"))
+	a.Equal("shadowed", expand("${counter}"))
+
+	counter, err := expandTemplate("${counter}", pollLookup(nil, 7))
+	a.NoError(err)
+	a.Equal("7", counter)
+
+	n, err := strconv.Atoi(expand("${randInt:5:6}"))
+	a.NoError(err)
+	a.True(n == 5 || n == 6)
+
+	for _, invalid := range []string{"unknown", "uuid:x", "date", "randInt:5", "randInt:9:1"} {
+		_, err := parseBuiltin(invalid)
+		a.Error(err, invalid)
+	}
+
+	_, err = expandTemplate("${env:POLLER_TEST_MISSING}", lookup)
+	a.True(errors.Is(err, ErrUnknownVariable))
+}
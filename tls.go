@@ -0,0 +1,131 @@
+package websitepoller
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	pinnedKeyPrefix string = "sha256/"
+)
+
+func parseTLSOptions(id string, opts *TLSOptions) (*tls.Config, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	l := log.With().Str("id", id).Logger()
+	v := &validator{}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+
+	switch opts.MinVersion {
+	case "":
+	case "1.0":
+		cfg.MinVersion = tls.VersionTLS10
+	case "1.1":
+		cfg.MinVersion = tls.VersionTLS11
+	case "1.2":
+		cfg.MinVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		v.check("minVersion", fmt.Errorf("%w: unsupported version %s", ErrInvalidTLSOptions, opts.MinVersion))
+	}
+	if cfg.MinVersion < tls.VersionTLS12 {
+		l.Warn().Str("minVersion", opts.MinVersion).Msg("allowing deprecated tls versions")
+	}
+
+	if len(opts.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if v.check("caFile", err) {
+			cfg.RootCAs = x509.NewCertPool()
+			if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+				v.check("caFile", fmt.Errorf("%w: no certificates found in %s", ErrInvalidTLSOptions, opts.CAFile))
+			}
+		}
+	}
+
+	if len(opts.CertFile) > 0 || len(opts.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if v.check("certFile", err) {
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	if len(opts.PinnedKeys) > 0 {
+		pins, err := parsePinnedKeys(opts.PinnedKeys)
+		if v.check("pinnedKeys", err) {
+			cfg.VerifyConnection = verifyPinnedKeys(pins, opts.InsecureSkipVerify)
+		}
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if opts.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		l.Error().Msg("!!! TLS CERTIFICATE VERIFICATION IS DISABLED: connections to this page can be intercepted, never use this in production !!!")
+	}
+
+	return cfg, nil
+}
+
+func parsePinnedKeys(keys []string) (map[string]bool, error) {
+	v := &validator{}
+	pins := map[string]bool{}
+
+	for i, key := range keys {
+		key = strings.TrimPrefix(key, pinnedKeyPrefix)
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(decoded) != sha256.Size {
+			v.check(indexPath(i), fmt.Errorf("%w: pinned key %s is not a base64-encoded sha256 hash", ErrInvalidTLSOptions, key))
+			continue
+		}
+		pins[string(decoded)] = true
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+// verifyPinnedKeys returns a function that checks that a certificate of the
+// server has one of the pinned public keys. The certificates presented by
+// the server are chosen by whoever answers, so only the verified chains are
+// checked or, when verification is skipped, only the leaf certificate.
+func verifyPinnedKeys(pins map[string]bool, insecure bool) func(tls.ConnectionState) error {
+	pinned := func(cert *x509.Certificate) bool {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		return pins[string(sum[:])]
+	}
+
+	return func(cs tls.ConnectionState) error {
+		if insecure {
+			if len(cs.PeerCertificates) > 0 && pinned(cs.PeerCertificates[0]) {
+				return nil
+			}
+			return ErrPinnedKeyMismatch
+		}
+
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				if pinned(cert) {
+					return nil
+				}
+			}
+		}
+
+		return ErrPinnedKeyMismatch
+	}
+}
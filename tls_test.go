@@ -0,0 +1,170 @@
+package websitepoller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSOptions(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	a.NoError(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+	spki := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(spki[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	certFile, keyFile := writeClientCert(t, dir, "poller-client")
+
+	poll := func(opts *TLSOptions) *Result {
+		p, err := New(&Page{URL: server.URL, TLSOptions: opts})
+		a.NoError(err)
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		return res
+	}
+
+	var certErr x509.UnknownAuthorityError
+	a.True(errors.As(poll(&TLSOptions{}).Err, &certErr))
+	a.NoError(poll(&TLSOptions{CAFile: caFile}).Err)
+	a.NoError(poll(&TLSOptions{CAFile: caFile, PinnedKeys: []string{otherPin, pin}}).Err)
+	a.True(errors.Is(poll(&TLSOptions{CAFile: caFile, PinnedKeys: []string{otherPin}}).Err, ErrPinnedKeyMismatch))
+	a.NoError(poll(&TLSOptions{InsecureSkipVerify: true}).Err)
+	a.True(errors.Is(poll(&TLSOptions{InsecureSkipVerify: true, PinnedKeys: []string{otherPin}}).Err, ErrPinnedKeyMismatch))
+
+	res := poll(&TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})
+	a.NoError(res.Err)
+	a.Equal("poller-client", res.Response.Header.Get("X-Client"))
+
+	for _, invalid := range []*TLSOptions{
+		{MinVersion: "2.0"},
+		{PinnedKeys: []string{"not-a-hash"}},
+		{CAFile: certFile + ".missing"},
+		{CertFile: certFile},
+	} {
+		_, err := New(&Page{URL: server.URL, TLSOptions: invalid})
+		a.Error(err)
+	}
+}
+
+func TestPinnedKeysForgedChain(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	// -- The pinned certificate is appended to the one of an impostor
+	pinnedServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer pinnedServer.Close()
+	spki := sha256.Sum256(pinnedServer.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(spki[:])
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "impostor"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	a.NoError(err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{der, pinnedServer.Certificate().Raw},
+		PrivateKey:  key,
+	}}}
+	server.StartTLS()
+	defer server.Close()
+
+	// -- The impostor is even trusted, i.e. it was mis-issued
+	caFile := filepath.Join(dir, "ca.pem")
+	a.NoError(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+
+	poll := func(opts *TLSOptions) *Result {
+		p, err := New(&Page{URL: server.URL, TLSOptions: opts})
+		a.NoError(err)
+
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		return res
+	}
+
+	a.NoError(poll(&TLSOptions{CAFile: caFile}).Err)
+	a.True(errors.Is(poll(&TLSOptions{CAFile: caFile, PinnedKeys: []string{pin}}).Err, ErrPinnedKeyMismatch))
+	a.True(errors.Is(poll(&TLSOptions{InsecureSkipVerify: true, PinnedKeys: []string{pin}}).Err, ErrPinnedKeyMismatch))
+
+	// -- The certificate actually presented can still be pinned
+	cert, err := x509.ParseCertificate(der)
+	a.NoError(err)
+	spki = sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	a.NoError(poll(&TLSOptions{InsecureSkipVerify: true, PinnedKeys: []string{base64.StdEncoding.EncodeToString(spki[:])}}).Err)
+}
+
+func writeClientCert(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
@@ -0,0 +1,102 @@
+package websitepoller
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings contains the duration of each phase of the request to the page.
+// Phases that did not happen, i.e. DNS lookup and connection when an idle
+// connection was reused, are zero.
+type Timings struct {
+	// DNSLookup is the time taken to resolve the host
+	DNSLookup time.Duration `json:"dnsLookup"`
+	// TCPConnect is the time taken to establish the TCP connection
+	TCPConnect time.Duration `json:"tcpConnect"`
+	// TLSHandshake is the time taken by the TLS handshake
+	TLSHandshake time.Duration `json:"tlsHandshake"`
+	// TimeToFirstByte is the time from the start of the request until the
+	// first byte of the response was received
+	TimeToFirstByte time.Duration `json:"timeToFirstByte"`
+	// Transfer is the time taken to read the body after the first byte
+	Transfer time.Duration `json:"transfer"`
+	// Total is the time from the start of the request until the body was
+	// read
+	Total time.Duration `json:"total"`
+	// ConnReused specifies whether an idle connection was reused
+	ConnReused bool `json:"connReused"`
+}
+
+// pollTrace collects the times of the phases of a request. Hooks can be
+// called from different goroutines, so they are guarded by a lock.
+type pollTrace struct {
+	start      time.Time
+	dnsStart   time.Time
+	dnsDone    time.Time
+	connStart  time.Time
+	connDone   time.Time
+	tlsStart   time.Time
+	tlsDone    time.Time
+	firstByte  time.Time
+	connReused bool
+	lock       sync.Mutex
+}
+
+func (t *pollTrace) mark(at *time.Time) func() {
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		*at = time.Now()
+	}
+}
+
+func (t *pollTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			// -- Redirects get new connections: only the first one counts
+			if t.start.IsZero() {
+				t.start = time.Now()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.connReused = info.Reused
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone)() },
+		ConnectStart:         func(string, string) { t.mark(&t.connStart)() },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connDone)() },
+		TLSHandshakeStart:    t.mark(&t.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone)() },
+		GotFirstResponseByte: t.mark(&t.firstByte),
+	}
+}
+
+// timings returns the durations of the phases, given the time when the body
+// was read
+func (t *pollTrace) timings(done time.Time) *Timings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+
+	return &Timings{
+		DNSLookup:       between(t.dnsStart, t.dnsDone),
+		TCPConnect:      between(t.connStart, t.connDone),
+		TLSHandshake:    between(t.tlsStart, t.tlsDone),
+		TimeToFirstByte: between(t.start, t.firstByte),
+		Transfer:        between(t.firstByte, done),
+		Total:           between(t.start, done),
+		ConnReused:      t.connReused,
+	}
+}
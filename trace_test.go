@@ -0,0 +1,130 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	// -- Own transport, so that the first poll needs a new connection
+	p, err := New(&Page{URL: server.URL, Trace: true})
+	a.NoError(err)
+	p.(*pagePoller).httpClient.Transport = &http.Transport{}
+
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	poll := p.(*pagePoller).poll
+
+	poll(context.Background())
+	a.NoError(res.Err)
+	a.NotNil(res.Timings)
+	a.False(res.Timings.ConnReused)
+	a.True(res.Timings.TCPConnect > 0)
+	a.Zero(res.Timings.TLSHandshake)
+	a.True(res.Timings.TimeToFirstByte >= 20*time.Millisecond)
+	a.True(res.Timings.Transfer >= 10*time.Millisecond)
+	a.True(res.Timings.Total >= res.Timings.TimeToFirstByte+res.Timings.Transfer)
+
+	poll(context.Background())
+	a.True(res.Timings.ConnReused)
+	a.Zero(res.Timings.TCPConnect)
+
+	stats := p.Stats()
+	a.Equal(uint64(1), stats.ReusedConnections)
+	a.Equal(res.Timings, stats.LastTimings)
+
+	// -- No tracing by default
+	p, err = New(&Page{URL: server.URL})
+	a.NoError(err)
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+	a.Nil(res.Timings)
+	a.Nil(p.Stats().LastTimings)
+}
+
+func TestTraceOAuth2(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("POLLER_TEST_SECRET", "client-secret")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := New(&Page{
+		URL:   server.URL + "/page",
+		Trace: true,
+		AuthOptions: &AuthOptions{OAuth2: &OAuth2Auth{
+			TokenURL:     server.URL + "/token",
+			ClientID:     "client",
+			ClientSecret: Secret{Env: "POLLER_TEST_SECRET"},
+		}},
+	})
+	a.NoError(err)
+
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	p.(*pagePoller).poll(context.Background())
+
+	// -- The token request is not part of the timings of the page
+	a.NoError(res.Err)
+	a.True(res.Timings.TimeToFirstByte < 300*time.Millisecond)
+	a.True(res.Timings.Total < 300*time.Millisecond)
+}
+
+func TestTraceFailedRequest(t *testing.T) {
+	a := assert.New(t)
+
+	// -- The server accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	p, err := New(&Page{URL: "http://" + listener.Addr().String(), Trace: true})
+	a.NoError(err)
+	p.(*pagePoller).httpClient.Transport = &http.Transport{}
+
+	var res *Result
+	p.SetHandlerFunc(func(r *Result) { res = r })
+	ctx, canc := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer canc()
+	p.(*pagePoller).poll(ctx)
+
+	a.True(errors.Is(res.Err, ErrTimeout))
+	a.NotNil(res.Timings)
+	a.True(res.Timings.TCPConnect > 0)
+	a.Zero(res.Timings.TimeToFirstByte)
+	a.Zero(res.Timings.Transfer)
+	a.True(res.Timings.Total >= 100*time.Millisecond)
+	a.Nil(p.Stats().LastTimings)
+}
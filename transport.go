@@ -0,0 +1,406 @@
+package websitepoller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxOrderedHeadSize is the maximum size of the head of a request that
+	// is buffered to be reordered: bigger heads are written as they are.
+	maxOrderedHeadSize int = 1 << 20
+	// HTTPVersion1 forces HTTP/1.1
+	HTTPVersion1 string = "1.1"
+	// HTTPVersion2 forces HTTP/2
+	HTTPVersion2 string = "2"
+)
+
+var (
+	headEnd = []byte("\r\n\r\n")
+	// sharedTransports contains the transports shared among pollers, by
+	// their options, along with the number of pollers using them
+	sharedTransports     = map[string]*sharedTransportEntry{}
+	sharedTransportsLock sync.Mutex
+)
+
+type sharedTransportEntry struct {
+	transport *http.Transport
+	refs      int
+}
+
+// newTransport returns the transport for the page, or nil if the default
+// one can be used.
+func newTransport(id string, p *Page, tlsConfig *tls.Config) (http.RoundTripper, error) {
+	opts := p.TransportOptions
+	preserveOrder := preservesHeaderOrder(p)
+	if err := checkTransportOptions(opts, preserveOrder); err != nil {
+		return nil, err
+	}
+
+	if opts == nil && tlsConfig == nil && !preserveOrder {
+		return nil, nil
+	}
+
+	if opts == nil || !opts.Shared {
+		return buildTransport(p, tlsConfig), nil
+	}
+
+	if preserveOrder {
+		log.Warn().Str("id", id).Msg("transport cannot be shared when preserving header order, using a dedicated one")
+		return buildTransport(p, tlsConfig), nil
+	}
+
+	// -- Options are plain values, so their encoding identifies them
+	key, err := json.Marshal(struct {
+		Transport *TransportOptions
+		TLS       *TLSOptions
+	}{opts, p.TLSOptions})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTransportOptions, err)
+	}
+
+	return &sharedTransport{
+		key: string(key),
+		build: func() *http.Transport {
+			return buildTransport(p, tlsConfig)
+		},
+	}, nil
+}
+
+// sharedTransport is the transport of a poller that shares its connections
+// with the other pollers that have the same options. The underlying
+// transport is only taken from the shared ones, or created, while the
+// poller holds it - i.e. while it runs or polls - so that validating a page
+// has no side effects, and it is released when the poller stops.
+type sharedTransport struct {
+	key       string
+	build     func() *http.Transport
+	transport *http.Transport
+	holders   int
+	lock      sync.Mutex
+}
+
+// RoundTrip sends the request with the shared transport, holding it until
+// the response is received
+func (s *sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := s.hold()
+	defer s.release()
+
+	return transport.RoundTrip(req)
+}
+
+// hold returns the shared transport, and counts the poller among the ones
+// using it if it did not hold it yet. Each call must be followed by a call
+// to release.
+func (s *sharedTransport) hold() *http.Transport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.holders++
+	if s.transport != nil {
+		return s.transport
+	}
+
+	sharedTransportsLock.Lock()
+	defer sharedTransportsLock.Unlock()
+	entry, exists := sharedTransports[s.key]
+	if !exists {
+		entry = &sharedTransportEntry{transport: s.build()}
+		sharedTransports[s.key] = entry
+	}
+	entry.refs++
+	s.transport = entry.transport
+
+	return s.transport
+}
+
+// release stops holding the shared transport. Once the poller does not
+// hold it anymore, it stops using it: the transport is removed and has its
+// idle connections closed once no poller uses it.
+func (s *sharedTransport) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.holders--; s.holders > 0 {
+		return
+	}
+	s.transport = nil
+
+	sharedTransportsLock.Lock()
+	defer sharedTransportsLock.Unlock()
+	entry := sharedTransports[s.key]
+	if entry.refs--; entry.refs == 0 {
+		delete(sharedTransports, s.key)
+		entry.transport.CloseIdleConnections()
+	}
+}
+
+// checkTransportOptions returns an error if the transport options cannot
+// be used. All problems are returned in a *ValidationError.
+func checkTransportOptions(opts *TransportOptions, preserveOrder bool) error {
+	if opts == nil {
+		return nil
+	}
+
+	v := &validator{}
+	switch opts.HTTPVersion {
+	case "", HTTPVersion1:
+	case HTTPVersion2:
+		if preserveOrder {
+			v.check("httpVersion", fmt.Errorf("%w: http/2 cannot be used when preserving header order", ErrInvalidTransportOptions))
+		}
+	case "3":
+		// -- Go's standard library has no HTTP/3 client
+		v.check("httpVersion", fmt.Errorf("%w: http/3 is not supported, use %q or %q", ErrInvalidTransportOptions, HTTPVersion1, HTTPVersion2))
+	default:
+		v.check("httpVersion", fmt.Errorf("%w: unsupported http version %s", ErrInvalidTransportOptions, opts.HTTPVersion))
+	}
+
+	limitErr := fmt.Errorf("%w: connection limits cannot be negative", ErrInvalidTransportOptions)
+	if opts.MaxIdleConns < 0 {
+		v.check("maxIdleConns", limitErr)
+	}
+	if opts.MaxIdleConnsPerHost < 0 {
+		v.check("maxIdleConnsPerHost", limitErr)
+	}
+	if opts.MaxConnsPerHost < 0 {
+		v.check("maxConnsPerHost", limitErr)
+	}
+
+	timeoutErr := fmt.Errorf("%w: timeouts cannot be negative", ErrInvalidTransportOptions)
+	if opts.IdleConnTimeout < 0 {
+		v.check("idleConnTimeout", timeoutErr)
+	}
+	if opts.DialTimeout < 0 {
+		v.check("dialTimeout", timeoutErr)
+	}
+	if opts.ResponseHeaderTimeout < 0 {
+		v.check("responseHeaderTimeout", timeoutErr)
+	}
+
+	return v.err()
+}
+
+// buildTransport returns a new transport for the page, with the options
+// not set taken from the default one
+func buildTransport(p *Page, tlsConfig *tls.Config) *http.Transport {
+	opts := p.TransportOptions
+	if opts == nil {
+		opts = &TransportOptions{}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if opts.DialTimeout > 0 {
+		dialer.Timeout = time.Duration(opts.DialTimeout)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSClientConfig = tlsConfig
+	transport.DisableKeepAlives = opts.DisableKeepAlives
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	transport.ResponseHeaderTimeout = time.Duration(opts.ResponseHeaderTimeout)
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(opts.IdleConnTimeout)
+	}
+
+	switch opts.HTTPVersion {
+	case HTTPVersion1:
+		transport.ForceAttemptHTTP2 = false
+		transport.Protocols = &http.Protocols{}
+		transport.Protocols.SetHTTP1(true)
+	case HTTPVersion2:
+		transport.Protocols = &http.Protocols{}
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if preservesHeaderOrder(p) {
+		preserveHeaderOrder(transport, dialer)
+	}
+
+	return transport
+}
+
+// preservesHeaderOrder returns true if the page or any of its steps must
+// write headers in the order they are defined
+func preservesHeaderOrder(p *Page) bool {
+	if p.PreserveHeaderOrder {
+		return true
+	}
+	for _, s := range p.Steps {
+		if s.PreserveHeaderOrder {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerOrder returns the position of each header, by lowercase name, or
+// nil if the order does not have to be preserved
+func headerOrder(preserve bool, headers Headers) map[string]int {
+	if !preserve {
+		return nil
+	}
+
+	order := map[string]int{}
+	for i, key := range headers.Keys() {
+		if _, exists := order[strings.ToLower(key)]; !exists {
+			order[strings.ToLower(key)] = i
+		}
+	}
+
+	return order
+}
+
+type headerOrderKey struct{}
+
+// withHeaderOrder returns a context that makes the connections dialed for
+// the request write its headers in the given order. Nothing changes if
+// order is nil.
+func withHeaderOrder(ctx context.Context, order map[string]int) context.Context {
+	if order == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, headerOrderKey{}, order)
+}
+
+// orderedConn wraps conn so that it writes headers in the order of the
+// request it was dialed for, if any
+func orderedConn(ctx context.Context, conn net.Conn) net.Conn {
+	order, ok := ctx.Value(headerOrderKey{}).(map[string]int)
+	if !ok {
+		return conn
+	}
+
+	return &orderedHeadConn{Conn: conn, order: order}
+}
+
+// preserveHeaderOrder makes the transport write headers in the order of
+// each request, as set by withHeaderOrder
+func preserveHeaderOrder(transport *http.Transport, dialer *net.Dialer) {
+	// -- Each connection is only used for one request, so that the
+	// connection only needs to reorder the first head written on it.
+	transport.DisableKeepAlives = true
+	transport.ForceAttemptHTTP2 = false
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return orderedConn(ctx, conn), nil
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			cfg = transport.TLSClientConfig.Clone()
+		}
+		if len(cfg.ServerName) == 0 {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		cfg.NextProtos = []string{"http/1.1"}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return orderedConn(ctx, tlsConn), nil
+	}
+}
+
+// orderedHeadConn is a connection that reorders the headers of the first
+// request written on it, because Go always writes them in alphabetical
+// order.
+type orderedHeadConn struct {
+	net.Conn
+	order map[string]int
+	head  []byte
+	done  bool
+}
+
+// Write buffers data until the whole head of the request is written, then
+// writes it with the headers reordered.
+func (c *orderedHeadConn) Write(data []byte) (int, error) {
+	if c.done {
+		return c.Conn.Write(data)
+	}
+
+	c.head = append(c.head, data...)
+	end := bytes.Index(c.head, headEnd)
+	if end < 0 && len(c.head) < maxOrderedHeadSize {
+		return len(data), nil
+	}
+
+	buffered := c.head
+	if end >= 0 {
+		buffered = append(reorderHead(c.head[:end], c.order), c.head[end:]...)
+	}
+	c.head, c.done = nil, true
+
+	if _, err := c.Conn.Write(buffered); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// reorderHead sorts the header lines of head - without the final empty line -
+// according to order. Headers not in order are written after the others,
+// except for Host, which is always first unless it is in order.
+func reorderHead(head []byte, order map[string]int) []byte {
+	lines := bytes.Split(head, []byte("\r\n"))
+	if len(lines) < 2 {
+		return head
+	}
+
+	headers := lines[1:]
+	rank := func(line []byte) int {
+		key := line
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			key = line[:i]
+		}
+		key = bytes.ToLower(bytes.TrimSpace(key))
+
+		if pos, exists := order[string(key)]; exists {
+			return pos
+		}
+		if string(key) == "host" {
+			return -1
+		}
+
+		return len(order)
+	}
+	sort.SliceStable(headers, func(i, j int) bool {
+		return rank(headers[i]) < rank(headers[j])
+	})
+
+	return bytes.Join(lines, []byte("\r\n"))
+}
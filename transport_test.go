@@ -0,0 +1,214 @@
+package websitepoller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportOptions(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		proto string
+		conns int32
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		proto = r.Proto
+	})
+	countConns := func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = &http.Protocols{}
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Config.ConnState = countConns
+	server.Start()
+	defer server.Close()
+
+	poll := func(url string, opts *TransportOptions) *Result {
+		page := &Page{URL: url, TransportOptions: opts}
+		if url == tlsServer.URL {
+			page.TLSOptions = &TLSOptions{InsecureSkipVerify: true}
+		}
+
+		p, err := New(page)
+		a.NoError(err)
+		var res *Result
+		p.SetHandlerFunc(func(r *Result) { res = r })
+		p.(*pagePoller).poll(context.Background())
+		return res
+	}
+
+	// -- HTTP version
+	a.NoError(poll(tlsServer.URL, nil).Err)
+	a.Equal("HTTP/2.0", proto)
+	a.NoError(poll(tlsServer.URL, &TransportOptions{HTTPVersion: HTTPVersion1}).Err)
+	a.Equal("HTTP/1.1", proto)
+	a.NoError(poll(server.URL, &TransportOptions{}).Err)
+	a.Equal("HTTP/1.1", proto)
+	a.NoError(poll(server.URL, &TransportOptions{HTTPVersion: HTTPVersion2}).Err)
+	a.Equal("HTTP/2.0", proto)
+
+	// -- Keep-alive
+	pollTwice := func(opts *TransportOptions) int32 {
+		atomic.StoreInt32(&conns, 0)
+		p, err := New(&Page{URL: server.URL, TransportOptions: opts})
+		a.NoError(err)
+		p.SetHandlerFunc(func(r *Result) { a.NoError(r.Err) })
+		p.(*pagePoller).poll(context.Background())
+		p.(*pagePoller).poll(context.Background())
+		return atomic.LoadInt32(&conns)
+	}
+	a.Equal(int32(1), pollTwice(&TransportOptions{}))
+	a.Equal(int32(2), pollTwice(&TransportOptions{DisableKeepAlives: true}))
+
+	// -- Timeouts
+	res := poll(server.URL+"/slow", &TransportOptions{ResponseHeaderTimeout: Duration(50 * time.Millisecond)})
+	a.True(errors.Is(res.Err, ErrTimeout))
+	a.NoError(poll(server.URL+"/slow", &TransportOptions{ResponseHeaderTimeout: Duration(time.Second)}).Err)
+}
+
+func TestSharedTransport(t *testing.T) {
+	a := assert.New(t)
+
+	var closed int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	newPoller := func(p *Page) *pagePoller {
+		poller, err := New(p)
+		a.NoError(err)
+		return poller.(*pagePoller)
+	}
+	transport := func(p *pagePoller) http.RoundTripper {
+		if shared, ok := p.httpClient.Transport.(*sharedTransport); ok {
+			defer shared.release()
+			return shared.hold()
+		}
+		return p.httpClient.Transport
+	}
+	pooled := func() int {
+		sharedTransportsLock.Lock()
+		defer sharedTransportsLock.Unlock()
+		return len(sharedTransports)
+	}
+	refs := func(p *pagePoller) int {
+		sharedTransportsLock.Lock()
+		defer sharedTransportsLock.Unlock()
+		entry, exists := sharedTransports[p.httpClient.Transport.(*sharedTransport).key]
+		if !exists {
+			return 0
+		}
+		return entry.refs
+	}
+	run := func(p *pagePoller) func() {
+		ctx, canc := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			p.Start(ctx, false)
+			close(stopped)
+		}()
+		return func() {
+			canc()
+			<-stopped
+		}
+	}
+
+	a.Nil(newPoller(&Page{URL: server.URL}).httpClient.Transport)
+
+	// -- Validating a page or creating its poller has no side effects
+	page := &Page{URL: server.URL, TransportOptions: &TransportOptions{MaxConnsPerHost: 4, Shared: true}}
+	a.NoError(Validate(page))
+	a.NoError(ValidateStrict(page))
+	first := newPoller(page)
+	second := newPoller(&Page{URL: server.URL + "/other", TransportOptions: &TransportOptions{MaxConnsPerHost: 4, Shared: true}})
+	a.Zero(pooled())
+
+	// -- Polls of pollers that are not running release the transport
+	first.poll(context.Background())
+	second.Poll(context.Background())
+	a.Zero(pooled())
+
+	// -- Running pollers with the same options share the transport
+	stopFirst, stopSecond := run(first), run(second)
+	a.Eventually(func() bool { return refs(first) == 2 }, time.Second, 5*time.Millisecond)
+	a.Equal(1, pooled())
+	shared := transport(first)
+	a.Same(shared, transport(second))
+	a.Equal(4, shared.(*http.Transport).MaxConnsPerHost)
+	first.poll(context.Background())
+	a.Equal(2, refs(first))
+
+	// -- Different options, or isolated transports
+	for _, p := range []*Page{
+		{URL: server.URL, TransportOptions: &TransportOptions{MaxConnsPerHost: 5, Shared: true}},
+		{URL: server.URL, TransportOptions: &TransportOptions{MaxConnsPerHost: 4, Shared: true}, TLSOptions: &TLSOptions{MinVersion: "1.3"}},
+		{URL: server.URL, TransportOptions: &TransportOptions{MaxConnsPerHost: 4}},
+		{URL: server.URL, TransportOptions: &TransportOptions{MaxConnsPerHost: 4, Shared: true}, PreserveHeaderOrder: true},
+	} {
+		a.NotSame(shared, transport(newPoller(p)))
+	}
+	a.Equal(1, pooled())
+
+	// -- The transport is released when the last poller using it stops
+	stopFirst()
+	a.Equal(1, refs(second))
+	stopSecond()
+	a.Zero(pooled())
+	first.poll(context.Background())
+	a.Zero(pooled())
+
+	// -- Dedicated transports close their connections when they stop
+	dedicated := newPoller(&Page{URL: server.URL, TransportOptions: &TransportOptions{}})
+	stop := run(dedicated)
+	dedicated.poll(context.Background())
+	atomic.StoreInt32(&closed, 0)
+	stop()
+	a.Eventually(func() bool { return atomic.LoadInt32(&closed) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestCheckTransportOptions(t *testing.T) {
+	a := assert.New(t)
+
+	for _, page := range []*Page{
+		{TransportOptions: &TransportOptions{HTTPVersion: "3"}},
+		{TransportOptions: &TransportOptions{HTTPVersion: HTTPVersion2}, PreserveHeaderOrder: true},
+		{TransportOptions: &TransportOptions{MaxIdleConnsPerHost: -1}},
+		{TransportOptions: &TransportOptions{DialTimeout: Duration(-time.Second)}},
+	} {
+		page.URL = "https://example.com"
+		err := Validate(page)
+		a.True(errors.Is(err, ErrInvalidTransportOptions))
+		a.Contains(err.Error(), "transport.")
+	}
+
+	err := Validate(&Page{URL: "https://example.com", TransportOptions: &TransportOptions{HTTPVersion: "3"}})
+	a.Contains(err.Error(), `transport.httpVersion: invalid transport options: http/3 is not supported, use "1.1" or "2"`)
+}
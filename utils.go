@@ -1,7 +1,9 @@
 package websitepoller
 
 import (
+	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -55,7 +57,26 @@ func parseURL(rawurl string) (*url.URL, error) {
 	return parsed, nil
 }
 
-func parsePollOptions(id string, opts *PollOptions) (randFreq bool, freq int, offset int) {
+// parseTemplatedURL validates a URL that may contain variables. If the
+// scheme is a variable itself, the URL can only be validated when polling.
+func parseTemplatedURL(rawurl string) error {
+	if strings.HasPrefix(rawurl, "${") {
+		_, err := templateVars(rawurl)
+		return err
+	}
+
+	placeholder, err := expandTemplate(rawurl, func(string) (string, error) {
+		return "x", nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = parseURL(placeholder)
+	return err
+}
+
+func parsePollOptions(id string, opts *PollOptions, hosts []string) (randFreq bool, freq time.Duration, offset time.Duration) {
 	l := log.With().Str("id", id).Logger()
 	randFreq, freq, offset = false, defaultFrequency, 0
 
@@ -63,10 +84,17 @@ func parsePollOptions(id string, opts *PollOptions) (randFreq bool, freq int, of
 		return
 	}
 
-	if opts.Frequency >= minFrequency {
-		freq = opts.Frequency
-	} else {
-		l.Error().Int("frequency", freq).Int("default", defaultFrequency).Msg("invalid frequency provided, using default value...")
+	minFreq, minOff, err := pollMinimums(opts, hosts)
+	if err != nil {
+		l.Warn().Err(err).Str("default", minFrequency.String()).Msg("invalid minimum frequency provided, using default value...")
+	}
+
+	// -- The default frequency never bypasses a raised minimum
+	freq = max(defaultFrequency, minFreq)
+	if time.Duration(opts.Frequency) >= minFreq {
+		freq = time.Duration(opts.Frequency)
+	} else if opts.Frequency != 0 {
+		l.Error().Str("frequency", opts.Frequency.String()).Str("min", minFreq.String()).Str("default", freq.String()).Msg("invalid frequency provided, using default value...")
 	}
 
 	if !opts.RandomFrequency {
@@ -76,23 +104,87 @@ func parsePollOptions(id string, opts *PollOptions) (randFreq bool, freq int, of
 	randFreq = true
 	offset = defaultOffsetRange
 	if opts.OffsetRange != nil {
-		if *opts.OffsetRange >= minOffset {
-			offset = *opts.OffsetRange
+		if time.Duration(*opts.OffsetRange) >= minOff {
+			offset = time.Duration(*opts.OffsetRange)
 		} else {
-			l.Warn().Int("offset", *opts.OffsetRange).Int("default", defaultOffsetRange).Msg("invalid offset range provided, reverting to default...")
+			l.Warn().Str("offset", opts.OffsetRange.String()).Str("default", defaultOffsetRange.String()).Msg("invalid offset range provided, reverting to default...")
 		}
 	}
 
-	if freq-offset >= minFrequency {
+	if freq-offset >= minFreq {
 		return
 	}
 
-	l.Warn().Int("range", offset).Int("frequency", freq).Msg("offset is too low, reverting to default...")
+	l.Warn().Str("range", offset.String()).Str("frequency", freq.String()).Msg("offset is too low, reverting to default...")
 	offset = defaultOffsetRange
-	freq = defaultFrequency
+	freq = max(defaultFrequency, minFreq+defaultOffsetRange)
 	return
 }
 
+// pollMinimums returns the minimum frequency and offset range allowed by the
+// poll options. The minimum frequency can only be lowered down to
+// minFrequencyFloor, and only if all the requests of the page go to local
+// hosts: otherwise, the defaults are returned along with the reason.
+func pollMinimums(opts *PollOptions, hosts []string) (minFreq, minOff time.Duration, err error) {
+	minFreq, minOff = minFrequency, minOffset
+
+	switch m := time.Duration(opts.MinFrequency); {
+	case m < 0:
+		err = fmt.Errorf("%w: %s is negative", ErrInvalidFrequency, m)
+	case m == 0:
+	case m < minFrequencyFloor:
+		err = fmt.Errorf("%w: minimum %s is lower than %s", ErrInvalidFrequency, m, minFrequencyFloor)
+	case m < minFrequency && !allLocalHosts(hosts):
+		err = fmt.Errorf("%w: minimum can only be lower than %s for loopback or private hosts", ErrInvalidFrequency, minFrequency)
+	default:
+		minFreq = m
+	}
+
+	if minFreq < minOff {
+		minOff = minFreq
+	}
+
+	return
+}
+
+// requestHosts returns the hosts of the URLs of the page and of its steps.
+// Hosts defined with variables are returned as they are, so that they are
+// never local.
+func requestHosts(p *Page) []string {
+	urls := []string{p.URL}
+	for _, s := range p.Steps {
+		urls = append(urls, s.URL)
+	}
+
+	hosts := make([]string, len(urls))
+	for i, raw := range urls {
+		hosts[i] = raw
+		if u, err := url.Parse(raw); err == nil {
+			hosts[i] = u.Hostname()
+		}
+	}
+
+	return hosts
+}
+
+// allLocalHosts returns true if all hosts are localhost, or loopback,
+// private or link-local IP addresses
+func allLocalHosts(hosts []string) bool {
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			continue
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func parseUserAgentOptions(id string, opts *UserAgentOptions) (randUA bool, uas []string) {
 	l := log.With().Str("id", id).Logger()
 	randUA, uas = false, []string{}
@@ -116,9 +208,9 @@ func parseUserAgentOptions(id string, opts *UserAgentOptions) (randUA bool, uas
 	return
 }
 
-func nextRandomTick(min, max int) int {
+func nextRandomTick(min, max time.Duration) time.Duration {
 	rand.Seed(time.Now().UnixNano())
-	return min + rand.Intn(max-min)
+	return min + time.Duration(rand.Int63n(int64(max-min)))
 }
 
 func getNextUA(id string, userAgents []string, random bool, last int) (ua string, index int) {
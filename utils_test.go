@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -55,13 +56,15 @@ func TestParseHTTPMethod(t *testing.T) {
 func TestParsePollOptions(t *testing.T) {
 	a := assert.New(t)
 
-	invalidOff := -10
-	tooLowFreqRange := 24
+	invalidOff := Duration(-10 * time.Second)
+	tooLowFreqRange := Duration(24 * time.Second)
+	subSecondOff := Duration(200 * time.Millisecond)
 	cases := []struct {
 		arg     *PollOptions
+		hosts   []string
 		expRand bool
-		expFreq int
-		expOff  int
+		expFreq time.Duration
+		expOff  time.Duration
 	}{
 		{
 			expRand: false,
@@ -70,7 +73,7 @@ func TestParsePollOptions(t *testing.T) {
 		},
 		{
 			arg: &PollOptions{
-				Frequency: -1,
+				Frequency: Duration(-time.Second),
 			},
 			expRand: false,
 			expFreq: defaultFrequency,
@@ -78,7 +81,7 @@ func TestParsePollOptions(t *testing.T) {
 		},
 		{
 			arg: &PollOptions{
-				Frequency: 2,
+				Frequency: Duration(2 * time.Second),
 			},
 			expRand: false,
 			expFreq: defaultFrequency,
@@ -86,34 +89,34 @@ func TestParsePollOptions(t *testing.T) {
 		},
 		{
 			arg: &PollOptions{
-				Frequency: 25,
+				Frequency: Duration(25 * time.Second),
 			},
 			expRand: false,
-			expFreq: 25,
+			expFreq: 25 * time.Second,
 			expOff:  0,
 		},
 		{
 			arg: &PollOptions{
-				Frequency:       25,
+				Frequency:       Duration(25 * time.Second),
 				RandomFrequency: true,
 			},
 			expRand: true,
-			expFreq: 25,
+			expFreq: 25 * time.Second,
 			expOff:  defaultOffsetRange,
 		},
 		{
 			arg: &PollOptions{
-				Frequency:       25,
+				Frequency:       Duration(25 * time.Second),
 				RandomFrequency: true,
 				OffsetRange:     &invalidOff,
 			},
 			expRand: true,
-			expFreq: 25,
+			expFreq: 25 * time.Second,
 			expOff:  defaultOffsetRange,
 		},
 		{
 			arg: &PollOptions{
-				Frequency:       25,
+				Frequency:       Duration(25 * time.Second),
 				RandomFrequency: true,
 				OffsetRange:     &tooLowFreqRange,
 			},
@@ -121,10 +124,82 @@ func TestParsePollOptions(t *testing.T) {
 			expFreq: defaultFrequency,
 			expOff:  defaultOffsetRange,
 		},
+		{
+			arg: &PollOptions{
+				Frequency: Duration(500 * time.Millisecond),
+			},
+			expRand: false,
+			expFreq: defaultFrequency,
+			expOff:  0,
+		},
+		{
+			arg: &PollOptions{
+				Frequency:    Duration(500 * time.Millisecond),
+				MinFrequency: Duration(100 * time.Millisecond),
+			},
+			expRand: false,
+			expFreq: 500 * time.Millisecond,
+			expOff:  0,
+		},
+		{
+			arg: &PollOptions{
+				Frequency:       Duration(time.Second),
+				RandomFrequency: true,
+				OffsetRange:     &subSecondOff,
+				MinFrequency:    Duration(100 * time.Millisecond),
+			},
+			expRand: true,
+			expFreq: time.Second,
+			expOff:  200 * time.Millisecond,
+		},
+		{
+			arg: &PollOptions{
+				Frequency:    Duration(10 * time.Second),
+				MinFrequency: Duration(time.Minute),
+			},
+			hosts:   []string{"example.com"},
+			expRand: false,
+			expFreq: time.Minute,
+			expOff:  0,
+		},
+		{
+			arg: &PollOptions{
+				RandomFrequency: true,
+				MinFrequency:    Duration(time.Minute),
+			},
+			hosts:   []string{"example.com"},
+			expRand: true,
+			expFreq: time.Minute + defaultOffsetRange,
+			expOff:  defaultOffsetRange,
+		},
+		{
+			arg: &PollOptions{
+				Frequency:    Duration(500 * time.Millisecond),
+				MinFrequency: Duration(100 * time.Millisecond),
+			},
+			hosts:   []string{"10.0.0.1", "example.com"},
+			expRand: false,
+			expFreq: defaultFrequency,
+			expOff:  0,
+		},
+		{
+			arg: &PollOptions{
+				Frequency:    Duration(500 * time.Millisecond),
+				MinFrequency: Duration(time.Nanosecond),
+			},
+			expRand: false,
+			expFreq: defaultFrequency,
+			expOff:  0,
+		},
 	}
 
 	for i, currCase := range cases {
-		rand, freq, off := parsePollOptions("", currCase.arg)
+		// -- Minimums can only be lowered for local hosts
+		hosts := currCase.hosts
+		if hosts == nil {
+			hosts = []string{"localhost", "127.0.0.1", "192.168.1.10"}
+		}
+		rand, freq, off := parsePollOptions("", currCase.arg, hosts)
 
 		errRand := a.Equal(currCase.expRand, rand)
 		errFreq := a.Equal(currCase.expFreq, freq)
@@ -0,0 +1,161 @@
+package websitepoller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with a field of a page
+type FieldError struct {
+	// Path of the field, i.e. pollOptions.offsetRange or extractors[1].css
+	Path string
+	// Err is the problem
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the problem
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError contains all the problems found in a page. Check whether
+// it contains a problem with errors.Is, i.e. errors.Is(err, ErrURLNoScheme).
+type ValidationError struct {
+	// Errors contains the problems, in the order of the fields of the page
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid page: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// validator collects the problems found in a page
+type validator struct {
+	errs []*FieldError
+}
+
+// check records err, if not nil, as a problem of the field at path and
+// returns true if there was no problem. The problems of a *ValidationError,
+// i.e. returned when parsing a list of extractors, are recorded one by one
+// with their paths under path.
+func (v *validator) check(path string, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	if verr, ok := err.(*ValidationError); ok {
+		for _, e := range verr.Errors {
+			v.errs = append(v.errs, &FieldError{Path: joinPath(path, e.Path), Err: e.Err})
+		}
+		return false
+	}
+
+	v.errs = append(v.errs, &FieldError{Path: path, Err: err})
+	return false
+}
+
+// joinPath returns the path of field under parent, i.e. extractors[1].css.
+// An empty field is parent itself.
+func joinPath(parent, field string) string {
+	switch {
+	case len(field) == 0:
+		return parent
+	case len(parent) == 0:
+		return field
+	case strings.HasPrefix(field, "["):
+		return parent + field
+	default:
+		return parent + "." + field
+	}
+}
+
+// indexPath returns the path of the element at index i of a list
+func indexPath(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+// err returns a *ValidationError with the problems found, or nil
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Errors: v.errs}
+}
+
+// Validate returns a *ValidationError with all the problems of the page
+// that would make New fail, or nil if there are none. Invalid poll options
+// are not problems, as New falls back to the defaults for them: use
+// ValidateStrict to report them too.
+func Validate(p *Page) error {
+	_, err := newPagePoller(p, false)
+	return err
+}
+
+// ValidateStrict is like Validate, but also reports the values that New
+// would replace with the defaults, i.e. a frequency lower than the minimum.
+func ValidateStrict(p *Page) error {
+	_, err := newPagePoller(p, true)
+	return err
+}
+
+// checkPollOptions records the poll options that parsePollOptions would
+// replace with the defaults
+func checkPollOptions(v *validator, opts *PollOptions, hosts []string) {
+	if opts == nil {
+		return
+	}
+
+	minFreq, minOff, err := pollMinimums(opts, hosts)
+	v.check("pollOptions.minFrequency", err)
+
+	// -- Zero means not set
+	freq := defaultFrequency
+	switch f := time.Duration(opts.Frequency); {
+	case f < 0:
+		v.check("pollOptions.frequency", fmt.Errorf("%w: %s is negative", ErrInvalidFrequency, f))
+	case f == 0 && freq < minFreq:
+		v.check("pollOptions.frequency", fmt.Errorf("%w: default %s is lower than %s", ErrUnsupportedFrequency, freq, minFreq))
+	case f > 0 && f < minFreq:
+		v.check("pollOptions.frequency", fmt.Errorf("%w: %s is lower than %s", ErrUnsupportedFrequency, f, minFreq))
+	case f > 0:
+		freq = f
+	}
+
+	if !opts.RandomFrequency {
+		return
+	}
+
+	offset := defaultOffsetRange
+	if opts.OffsetRange != nil {
+		if time.Duration(*opts.OffsetRange) < minOff {
+			v.check("pollOptions.offsetRange", fmt.Errorf("%w: %s is lower than %s", ErrInvalidRandRange, opts.OffsetRange, minOff))
+			return
+		}
+		offset = time.Duration(*opts.OffsetRange)
+	}
+
+	if freq-offset < minFreq {
+		v.check("pollOptions.offsetRange", fmt.Errorf("%w: frequency minus offset range must be at least %s, got %s", ErrInvalidRandRange, minFreq, freq-offset))
+	}
+}
@@ -0,0 +1,222 @@
+package websitepoller
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	a := assert.New(t)
+
+	paths := func(err error) []string {
+		var valErr *ValidationError
+		if !errors.As(err, &valErr) {
+			return nil
+		}
+
+		list := []string{}
+		for _, e := range valErr.Errors {
+			list = append(list, e.Path)
+		}
+		return list
+	}
+
+	method := "fetch"
+	page := &Page{
+		URL:             "no-scheme/${unknown}",
+		Method:          &method,
+		Body:            "${missing}",
+		Extractors:      []Extractor{{Name: "price", CSS: "span"}, {Name: "price", CSS: "div"}},
+		SnapshotOptions: &SnapshotOptions{},
+		AuthOptions:     &AuthOptions{},
+	}
+
+	err := Validate(page)
+	a.Equal([]string{"method", "url", "extractors[1].name", "snapshots", "auth", "body"}, paths(err))
+	a.True(errors.Is(err, ErrUnrecognizedHTTPMethod))
+	a.True(errors.Is(err, ErrURLNoScheme))
+	a.True(errors.Is(err, ErrInvalidExtractor))
+	a.True(errors.Is(err, ErrInvalidSnapshotOptions))
+	a.True(errors.Is(err, ErrInvalidAuth))
+	a.True(errors.Is(err, ErrUnknownVariable))
+	a.False(errors.Is(err, ErrInvalidStep))
+	a.Contains(err.Error(), "invalid page: method: unrecognized http method; url: ")
+
+	var fieldErr *FieldError
+	a.True(errors.As(err, &fieldErr))
+	a.Equal("method", fieldErr.Path)
+
+	// -- New reports the same problems
+	p, newErr := New(page)
+	a.True(p == nil)
+	a.Equal(err, newErr)
+
+	// -- Variables captured by steps are defined
+	err = Validate(&Page{
+		URL:                    "https://example.com/${token}",
+		Headers:                Headers{{Key: "X-Unknown", Values: []string{"${nope}"}}},
+		Steps:                  []Step{{Name: "login", URL: "https://example.com", Captures: []Capture{{Extractor: Extractor{Name: "token"}, Header: "X-Token"}}}},
+		ChangeDetectionOptions: &ChangeDetectionOptions{Fields: []string{"price"}},
+	})
+	a.Equal([]string{"changeDetection", "headers"}, paths(err))
+	a.True(errors.Is(err, ErrUnknownChangeField))
+
+	// -- Variables are not checked if steps are invalid
+	err = Validate(&Page{URL: "https://example.com/${token}", Steps: []Step{{URL: "https://example.com"}}})
+	a.Equal([]string{"steps[0].name"}, paths(err))
+
+	a.NoError(Validate(&Page{URL: "https://example.com"}))
+}
+
+func TestValidateFieldPaths(t *testing.T) {
+	a := assert.New(t)
+
+	paths := func(err error) []string {
+		var valErr *ValidationError
+		if !errors.As(err, &valErr) {
+			return nil
+		}
+
+		list := []string{}
+		for _, e := range valErr.Errors {
+			list = append(list, e.Path)
+		}
+		return list
+	}
+
+	// -- All the problems are reported, each with the path of its field
+	err := Validate(&Page{
+		URL: "https://example.com",
+		Extractors: []Extractor{
+			{Name: "title", CSS: "h1"},
+			{Name: "price", CSS: "[["},
+			{Name: "stock"},
+			{Name: "sku", Regex: "("},
+		},
+	})
+	a.Equal([]string{"extractors[1].css", "extractors[2]", "extractors[3].regex"}, paths(err))
+
+	err = Validate(&Page{
+		URL: "https://example.com",
+		Steps: []Step{
+			{Name: "a", URL: "https://example.com", Captures: []Capture{
+				{Extractor: Extractor{Name: "token", XPath: "//["}},
+				{Extractor: Extractor{Name: "session"}, Header: "X-Session", Cookie: "session"},
+			}},
+			{Name: "a", URL: "example.com/${token}", Headers: Headers{{Key: "X-Id", Values: []string{"${id}"}}}},
+		},
+	})
+	a.Equal([]string{"steps[0].captures[0].xpath", "steps[0].captures[1]", "steps[1].name", "steps[1].url", "steps[1].headers"}, paths(err))
+	a.True(errors.Is(err, ErrURLNoScheme))
+	a.True(errors.Is(err, ErrUnknownVariable))
+
+	err = Validate(&Page{
+		URL: "https://example.com",
+		AuthOptions: &AuthOptions{OAuth2: &OAuth2Auth{
+			TokenURL:     "example.com/token",
+			ClientSecret: Secret{Env: "A", File: "b"},
+		}},
+		TLSOptions: &TLSOptions{
+			MinVersion: "0.9",
+			PinnedKeys: []string{"sha256/" + strings.Repeat("A", 43) + "=", "short", "sha256/nope"},
+		},
+		TransportOptions: &TransportOptions{MaxConnsPerHost: -1, DialTimeout: Duration(-time.Second)},
+	})
+	a.Equal([]string{
+		"tls.minVersion", "tls.pinnedKeys[1]", "tls.pinnedKeys[2]",
+		"transport.maxConnsPerHost", "transport.dialTimeout",
+		"auth.oauth2.tokenURL", "auth.oauth2.clientID", "auth.oauth2.clientSecret",
+	}, paths(err))
+	a.True(errors.Is(err, ErrInvalidSecret))
+	a.Contains(err.Error(), "auth.oauth2.clientID: ")
+}
+
+func TestValidateStrict(t *testing.T) {
+	a := assert.New(t)
+
+	seconds := func(s float64) Duration { return Duration(s * float64(time.Second)) }
+	offset := func(s float64) *Duration { o := seconds(s); return &o }
+	cases := []struct {
+		url    string
+		opts   *PollOptions
+		path   string
+		expErr error
+	}{
+		{},
+		{opts: &PollOptions{MaxPanics: 3}},
+		{opts: &PollOptions{Frequency: seconds(60), RandomFrequency: true, OffsetRange: offset(20)}},
+		{url: "http://127.0.0.1:8080", opts: &PollOptions{Frequency: seconds(0.5), RandomFrequency: true, OffsetRange: offset(0.2), MinFrequency: seconds(0.1)}},
+		{
+			opts:   &PollOptions{Frequency: seconds(30), MinFrequency: seconds(0.1)},
+			path:   "pollOptions.minFrequency",
+			expErr: ErrInvalidFrequency,
+		},
+		{
+			url:    "http://localhost:8080",
+			opts:   &PollOptions{Frequency: seconds(30), MinFrequency: seconds(0.001)},
+			path:   "pollOptions.minFrequency",
+			expErr: ErrInvalidFrequency,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(-1)},
+			path:   "pollOptions.frequency",
+			expErr: ErrInvalidFrequency,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(2)},
+			path:   "pollOptions.frequency",
+			expErr: ErrUnsupportedFrequency,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(60), RandomFrequency: true, OffsetRange: offset(1)},
+			path:   "pollOptions.offsetRange",
+			expErr: ErrInvalidRandRange,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(12), RandomFrequency: true},
+			path:   "pollOptions.offsetRange",
+			expErr: ErrInvalidRandRange,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(0.5)},
+			path:   "pollOptions.frequency",
+			expErr: ErrUnsupportedFrequency,
+		},
+		{
+			opts:   &PollOptions{MinFrequency: seconds(60)},
+			path:   "pollOptions.frequency",
+			expErr: ErrUnsupportedFrequency,
+		},
+		{
+			opts:   &PollOptions{Frequency: seconds(30), MinFrequency: seconds(-1)},
+			path:   "pollOptions.minFrequency",
+			expErr: ErrInvalidFrequency,
+		},
+	}
+
+	for _, c := range cases {
+		if len(c.url) == 0 {
+			c.url = "https://example.com"
+		}
+		page := &Page{URL: c.url, PollOptions: c.opts}
+
+		// -- Lenient mode falls back to the defaults
+		a.NoError(Validate(page))
+
+		err := ValidateStrict(page)
+		if c.expErr == nil {
+			a.NoError(err)
+			continue
+		}
+
+		var valErr *ValidationError
+		a.True(errors.As(err, &valErr))
+		a.Len(valErr.Errors, 1)
+		a.Equal(c.path, valErr.Errors[0].Path)
+		a.True(errors.Is(err, c.expErr))
+	}
+}